@@ -8,9 +8,29 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/vincentcr/huecontrol/services"
+	"github.com/vincentcr/huecontrol/api/services"
 )
 
+// mustAllow wraps mustAuthenticate, also requiring the token of the request,
+// if any, to have the scope, and the user to be verified if the scope is not
+// open to unverified accounts.
+func mustAllow(scope services.Scope, h handler) handler {
+	return mustAuthenticate(func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		if !c.GetAccess().Allows(scope) {
+			return NewHttpErrorWithText(http.StatusForbidden, fmt.Sprintf("Token does not have scope %v", scope))
+		}
+		if !c.Services.Users.AllowsUnverified(scope) {
+			verified, err := c.Services.Users.IsVerified(c.MustGetUser().ID)
+			if err != nil {
+				return err
+			} else if !verified {
+				return NewHttpErrorWithText(http.StatusForbidden, "Email address must be verified")
+			}
+		}
+		return h(c, w, r)
+	})
+}
+
 func mustAuthenticate(h handler) handler {
 	return func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
 		_, ok := c.GetUser()
@@ -18,8 +38,7 @@ func mustAuthenticate(h handler) handler {
 			w.Header().Set("WWW-Authenticate", "Basic realm=\"Please enter your username and password\"")
 			return NewHttpError(http.StatusUnauthorized)
 		}
-		h(c, w, r)
-		return nil
+		return h(c, w, r)
 	}
 }
 
@@ -33,6 +52,8 @@ var (
 )
 
 func authenticate(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+	var token services.Token
+	var access services.Access
 	verify := func(method AuthMethod, creds AuthCreds) (services.User, error) {
 		switch method {
 		case AuthMethodBasic:
@@ -40,8 +61,10 @@ func authenticate(c *HCContext, w http.ResponseWriter, r *http.Request) error {
 			password := creds[1]
 			return c.Services.Users.AuthenticateWithPassword(username, password)
 		case AuthMethodToken:
-			token := creds[0]
-			return c.Services.Users.AuthenticateWithToken(token)
+			token = services.Token(creds[0])
+			user, tokenAccess, err := c.Services.Users.AuthenticateWithToken(creds[0])
+			access = tokenAccess
+			return user, err
 		default:
 			return services.User{}, fmt.Errorf("Unknown auth method %v", method)
 		}
@@ -53,6 +76,10 @@ func authenticate(c *HCContext, w http.ResponseWriter, r *http.Request) error {
 		return nil
 	} else if err == nil {
 		c.Env["user"] = user
+		if token != "" {
+			c.Env["token"] = token
+			c.Env["access"] = access
+		}
 		log.Printf("Authenticated as %v", user)
 	}
 	return err
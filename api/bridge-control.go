@@ -0,0 +1,290 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/vincentcr/huecontrol/api/services"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+// bridgeHandler handles a request about one of the user's bridges, with a
+// client for that bridge.
+type bridgeHandler func(c *HCContext, client *hue.Client, w http.ResponseWriter, r *http.Request) error
+
+func withBridge(scope services.Scope, h bridgeHandler) handler {
+	return mustAllow(scope, func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		user := c.MustGetUser()
+		id, err := allowedBridgeIDParam(c)
+		if err != nil {
+			return err
+		}
+		bridge, err := c.Services.Bridges.Get(user.ID, id)
+		if err != nil {
+			return err
+		}
+		client, err := c.Services.Bridges.Client(bridge)
+		if err == services.ErrAddressNotAllowed {
+			return NewHttpErrorWithText(http.StatusForbidden, "Bridge address is not allowed")
+		} else if err != nil {
+			return err
+		}
+		return bridgeError(h(c, client.WithContext(r.Context()), w, r))
+	})
+}
+
+// allowedLights returns the lights of the groups of the bridge the token is
+// restricted to, or nil if it may control every light.
+func allowedLights(c *HCContext, client *hue.Client) (map[string]bool, error) {
+	access := c.GetAccess()
+	if !access.RestrictedToGroups() {
+		return nil, nil
+	}
+	groups, err := client.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+	bridge := bridgeIDParam(c)
+	lights := make(map[string]bool)
+	for _, group := range groups {
+		if access.AllowsGroup(bridge, group.ID) {
+			for _, id := range group.Lights {
+				lights[id] = true
+			}
+		}
+	}
+	return lights, nil
+}
+
+func mustAllowLight(c *HCContext, client *hue.Client, id string) error {
+	lights, err := allowedLights(c, client)
+	if err != nil {
+		return err
+	} else if lights != nil && !lights[id] {
+		return NewHttpErrorWithText(http.StatusForbidden, "Token does not allow this light")
+	}
+	return nil
+}
+
+func mustAllowGroup(c *HCContext, id string) error {
+	if !c.GetAccess().AllowsGroup(bridgeIDParam(c), id) {
+		return NewHttpErrorWithText(http.StatusForbidden, "Token does not allow this group")
+	}
+	return nil
+}
+
+// allowsScene tells whether the token may see the scene: that of an allowed
+// group, or whose lights are all allowed.
+func allowsScene(c *HCContext, lights map[string]bool, scene hue.Scene) bool {
+	if lights == nil {
+		return true
+	} else if scene.Group != "" {
+		return c.GetAccess().AllowsGroup(bridgeIDParam(c), scene.Group)
+	}
+	for _, id := range scene.Lights {
+		if !lights[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// bridgeError maps the errors of a bridge to the HTTP status of our response:
+// errors in the request are the client's, anything else means the bridge
+// could not do what was asked.
+func bridgeError(err error) error {
+	if err == nil {
+		return nil
+	} else if _, ok := err.(HttpError); ok {
+		return err
+	}
+
+	hueErr, ok := err.(hue.Error)
+	if !ok {
+		log.Printf("Bridge error: %v", err)
+		return NewHttpErrorWithText(http.StatusBadGateway, "Bridge unavailable")
+	}
+
+	var code int
+	switch hueErr.Type {
+	case hue.ErrCodesResourceNotAvailable, hue.ErrCodesMethodNotAvailable:
+		code = http.StatusNotFound
+	case hue.ErrCodesInvalidJSON, hue.ErrCodesMissingParameters, hue.ErrCodesParameterNotAvailable,
+		hue.ErrCodesInvalidValue, hue.ErrCodesParameterNotModifiable:
+		code = http.StatusBadRequest
+	case hue.ErrCodesDeviceIsOff:
+		code = http.StatusConflict
+	case hue.ErrCodesUnauthorizedUser, hue.ErrCodesLinkButtonNotPressed:
+		// our whitelist user was removed from the bridge: not the client's credentials
+		code = http.StatusBadGateway
+	default:
+		log.Printf("Bridge error: %v", err)
+		code = http.StatusBadGateway
+	}
+	return HttpError{StatusCode: code, StatusText: hueErr.Description, Data: hueErr}
+}
+
+func routeBridgeControl(m *Mux) {
+
+	m.Get("/api/1.0.0/bridges/:id/lights", withBridge(services.ScopeLightsRead, func(c *HCContext, client *hue.Client, w http.ResponseWriter, r *http.Request) error {
+		allowed, err := allowedLights(c, client)
+		if err != nil {
+			return err
+		}
+		lights, err := client.GetLights()
+		if err != nil {
+			return err
+		}
+		if allowed != nil {
+			filtered := make([]hue.Light, 0, len(lights))
+			for _, light := range lights {
+				if allowed[light.ID] {
+					filtered = append(filtered, light)
+				}
+			}
+			lights = filtered
+		}
+		return jsonify(lights, w)
+	}))
+
+	m.Get("/api/1.0.0/bridges/:id/lights/:light", withBridge(services.ScopeLightsRead, func(c *HCContext, client *hue.Client, w http.ResponseWriter, r *http.Request) error {
+		id := c.URLParams["light"]
+		if err := mustAllowLight(c, client, id); err != nil {
+			return err
+		}
+		light, err := client.GetLight(id)
+		if err != nil {
+			return err
+		}
+		return jsonify(light, w)
+	}))
+
+	m.Put("/api/1.0.0/bridges/:id/lights/:light/state", withBridge(services.ScopeLightsWrite, func(c *HCContext, client *hue.Client, w http.ResponseWriter, r *http.Request) error {
+		var state hue.StateUpdate
+		if err := parseBody(r, &state); err != nil {
+			return NewHttpError(http.StatusBadRequest)
+		}
+		id := c.URLParams["light"]
+		if err := mustAllowLight(c, client, id); err != nil {
+			return err
+		}
+		if err := client.SetLightState(id, state); err != nil {
+			return err
+		}
+		light, err := client.GetLight(id)
+		if err != nil {
+			return err
+		}
+		return jsonify(light, w)
+	}))
+
+	m.Get("/api/1.0.0/bridges/:id/groups", withBridge(services.ScopeLightsRead, func(c *HCContext, client *hue.Client, w http.ResponseWriter, r *http.Request) error {
+		groups, err := client.GetGroups()
+		if err != nil {
+			return err
+		}
+		bridge := bridgeIDParam(c)
+		filtered := make([]hue.Group, 0, len(groups))
+		for _, group := range groups {
+			if c.GetAccess().AllowsGroup(bridge, group.ID) {
+				filtered = append(filtered, group)
+			}
+		}
+		return jsonify(filtered, w)
+	}))
+
+	m.Get("/api/1.0.0/bridges/:id/groups/:group", withBridge(services.ScopeLightsRead, func(c *HCContext, client *hue.Client, w http.ResponseWriter, r *http.Request) error {
+		id := c.URLParams["group"]
+		if err := mustAllowGroup(c, id); err != nil {
+			return err
+		}
+		group, err := client.GetGroup(id)
+		if err != nil {
+			return err
+		}
+		return jsonify(group, w)
+	}))
+
+	m.Put("/api/1.0.0/bridges/:id/groups/:group/state", withBridge(services.ScopeLightsWrite, func(c *HCContext, client *hue.Client, w http.ResponseWriter, r *http.Request) error {
+		var state hue.StateUpdate
+		if err := parseBody(r, &state); err != nil {
+			return NewHttpError(http.StatusBadRequest)
+		}
+		id := c.URLParams["group"]
+		if err := mustAllowGroup(c, id); err != nil {
+			return err
+		}
+		if err := client.SetGroupState(id, state); err != nil {
+			return err
+		}
+		group, err := client.GetGroup(id)
+		if err != nil {
+			return err
+		}
+		return jsonify(group, w)
+	}))
+
+	m.Get("/api/1.0.0/bridges/:id/scenes", withBridge(services.ScopeLightsRead, func(c *HCContext, client *hue.Client, w http.ResponseWriter, r *http.Request) error {
+		allowed, err := allowedLights(c, client)
+		if err != nil {
+			return err
+		}
+		scenes, err := client.GetScenes()
+		if err != nil {
+			return err
+		}
+		filtered := make([]hue.Scene, 0, len(scenes))
+		for _, scene := range scenes {
+			if allowsScene(c, allowed, scene) {
+				filtered = append(filtered, scene)
+			}
+		}
+		return jsonify(filtered, w)
+	}))
+
+	m.Get("/api/1.0.0/bridges/:id/scenes/:scene", withBridge(services.ScopeLightsRead, func(c *HCContext, client *hue.Client, w http.ResponseWriter, r *http.Request) error {
+		allowed, err := allowedLights(c, client)
+		if err != nil {
+			return err
+		}
+		scene, err := client.GetScene(c.URLParams["scene"])
+		if err != nil {
+			return err
+		}
+		if !allowsScene(c, allowed, scene) {
+			return NewHttpErrorWithText(http.StatusForbidden, "Token does not allow this scene")
+		}
+		return jsonify(scene, w)
+	}))
+
+	// recalls the scene, on the group given in the body or else the scene's own group
+	m.Put("/api/1.0.0/bridges/:id/scenes/:scene/state", withBridge(services.ScopeLightsWrite, func(c *HCContext, client *hue.Client, w http.ResponseWriter, r *http.Request) error {
+		var recallReq struct {
+			Group string `json:"group"`
+		}
+		if r.ContentLength != 0 {
+			if err := parseBody(r, &recallReq); err != nil {
+				return NewHttpError(http.StatusBadRequest)
+			}
+		}
+
+		scene, err := client.GetScene(c.URLParams["scene"])
+		if err != nil {
+			return err
+		}
+		group := recallReq.Group
+		if group == "" {
+			group = scene.Group
+		}
+		if group == "" {
+			group = hue.AllLightsGroupID
+		}
+		if err := mustAllowGroup(c, group); err != nil {
+			return err
+		}
+		if err := client.RecallScene(group, scene.ID); err != nil {
+			return err
+		}
+		return jsonify(scene, w)
+	}))
+}
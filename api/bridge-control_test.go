@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vincentcr/huecontrol/api/services"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+func TestBridgeError(t *testing.T) {
+	assert.Nil(t, bridgeError(nil))
+
+	tests := map[int]error{
+		http.StatusNotFound:   hue.Error{Type: hue.ErrCodesResourceNotAvailable},
+		http.StatusBadRequest: hue.Error{Type: hue.ErrCodesInvalidValue},
+		http.StatusConflict:   hue.Error{Type: hue.ErrCodesDeviceIsOff},
+		http.StatusBadGateway: hue.Error{Type: hue.ErrCodesUnauthorizedUser},
+		http.StatusForbidden:  NewHttpError(http.StatusForbidden),
+	}
+	for code, err := range tests {
+		httpErr, ok := bridgeError(err).(HttpError)
+		if assert.True(t, ok, err.Error()) {
+			assert.Equal(t, code, httpErr.StatusCode, err.Error())
+		}
+	}
+
+	httpErr := bridgeError(fmt.Errorf("hue.Client GET http://bridge/api/user/lights: request: connection refused"))
+	assert.Equal(t, NewHttpErrorWithText(http.StatusBadGateway, "Bridge unavailable"), httpErr)
+}
+
+func TestAllowsScene(t *testing.T) {
+	bridge := services.RecordID("86eb1856a155497aac7fd7ef50e7d2df")
+	c := &HCContext{}
+	c.URLParams = map[string]string{"id": string(bridge)}
+	c.Env = map[interface{}]interface{}{"access": services.Access{Groups: []services.BridgeGroup{{bridge, "1"}}}}
+	lights := map[string]bool{"1": true, "2": true}
+
+	assert.True(t, allowsScene(c, lights, hue.Scene{Group: "1", Lights: []string{"1"}}))
+	assert.False(t, allowsScene(c, lights, hue.Scene{Group: "2", Lights: []string{"1"}}))
+
+	// the same group on another bridge
+	c.URLParams["id"] = "c3a4e1d2b5f6478899aabbccddeeff00"
+	assert.False(t, allowsScene(c, lights, hue.Scene{Group: "1", Lights: []string{"1"}}))
+	assert.NotNil(t, mustAllowGroup(c, "1"))
+	c.URLParams["id"] = string(bridge)
+	assert.Nil(t, mustAllowGroup(c, "1"))
+	assert.True(t, allowsScene(c, lights, hue.Scene{Lights: []string{"1", "2"}}))
+	assert.False(t, allowsScene(c, lights, hue.Scene{Lights: []string{"1", "3"}}))
+	assert.True(t, allowsScene(c, nil, hue.Scene{Group: "2"}))
+}
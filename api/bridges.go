@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/vincentcr/huecontrol/api/services"
+)
+
+type BridgeRequest struct {
+	BridgeID    string `json:"bridgeId" validate:"nonzero,max=32"`
+	Name        string `json:"name" validate:"nonzero,max=64"`
+	Address     string `json:"address" validate:"nonzero,max=256"`
+	Username    string `json:"username" validate:"nonzero,max=64"`
+	RemoteToken string `json:"remoteToken" validate:"max=256"`
+}
+
+type BridgeRenameRequest struct {
+	Name string `json:"name" validate:"nonzero,max=64"`
+}
+
+func routeBridges(m *Mux) {
+
+	m.Get("/api/1.0.0/bridges", mustAllow(services.ScopeLightsRead, func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		user := c.MustGetUser()
+		bridges, err := c.Services.Bridges.List(user.ID)
+		if err != nil {
+			return err
+		}
+		allowed := make([]services.Bridge, 0, len(bridges))
+		for _, bridge := range bridges {
+			if c.GetAccess().AllowsBridge(bridge.ID) {
+				allowed = append(allowed, bridge)
+			}
+		}
+		return jsonify(allowed, w)
+	}))
+
+	m.Post("/api/1.0.0/bridges", mustAllow(services.ScopeBridgesAdmin, func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		user := c.MustGetUser()
+		var bridgeReq BridgeRequest
+		if err := parseAndValidate(r, &bridgeReq); err != nil {
+			return err
+		}
+
+		bridge, err := c.Services.Bridges.Add(user.ID, services.Bridge{
+			BridgeID:    bridgeReq.BridgeID,
+			Name:        bridgeReq.Name,
+			Address:     bridgeReq.Address,
+			Username:    bridgeReq.Username,
+			RemoteToken: bridgeReq.RemoteToken,
+		})
+		if err == services.ErrUniqueViolation {
+			return HttpError{StatusCode: 400, StatusText: "Bridge already added"}
+		} else if err == services.ErrAddressNotAllowed {
+			return HttpError{StatusCode: 400, StatusText: "Bridge address must be an IP address on the local network"}
+		} else if err != nil {
+			return err
+		}
+
+		return jsonifyWithStatus(http.StatusCreated, bridge, w)
+	}))
+
+	m.Get("/api/1.0.0/bridges/:id", mustAllow(services.ScopeLightsRead, func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		user := c.MustGetUser()
+		id, err := allowedBridgeIDParam(c)
+		if err != nil {
+			return err
+		}
+		bridge, err := c.Services.Bridges.Get(user.ID, id)
+		if err != nil {
+			return err
+		}
+		return jsonify(bridge, w)
+	}))
+
+	m.Post("/api/1.0.0/bridges/:id/verify", mustAllow(services.ScopeBridgesAdmin, func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		user := c.MustGetUser()
+		id, err := allowedBridgeIDParam(c)
+		if err != nil {
+			return err
+		}
+		bridge, err := c.Services.Bridges.Verify(user.ID, id)
+		if verifyErr, ok := err.(services.VerificationError); ok {
+			return HttpError{StatusCode: http.StatusBadGateway, StatusText: verifyErr.Reason}
+		} else if err != nil {
+			return err
+		}
+		return jsonify(bridge, w)
+	}))
+
+	m.Put("/api/1.0.0/bridges/:id", mustAllow(services.ScopeBridgesAdmin, func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		user := c.MustGetUser()
+		var renameReq BridgeRenameRequest
+		if err := parseAndValidate(r, &renameReq); err != nil {
+			return err
+		}
+
+		id, err := allowedBridgeIDParam(c)
+		if err != nil {
+			return err
+		}
+		if err := c.Services.Bridges.Rename(user.ID, id, renameReq.Name); err != nil {
+			return err
+		}
+		bridge, err := c.Services.Bridges.Get(user.ID, id)
+		if err != nil {
+			return err
+		}
+		return jsonify(bridge, w)
+	}))
+
+	m.Delete("/api/1.0.0/bridges/:id", mustAllow(services.ScopeBridgesAdmin, func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		user := c.MustGetUser()
+		id, err := allowedBridgeIDParam(c)
+		if err != nil {
+			return err
+		}
+		if err := c.Services.Bridges.Remove(user.ID, id); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+}
+
+func bridgeIDParam(c *HCContext) services.RecordID {
+	return services.RecordID(c.URLParams["id"])
+}
+
+// allowedBridgeIDParam returns the bridge of the URL, if the token may use it.
+func allowedBridgeIDParam(c *HCContext) (services.RecordID, error) {
+	id := bridgeIDParam(c)
+	if !c.GetAccess().AllowsBridge(id) {
+		return "", NewHttpErrorWithText(http.StatusForbidden, "Token does not allow this bridge")
+	}
+	return id, nil
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"strings"
 
 	"gopkg.in/validator.v2"
 
-	"github.com/vincentcr/huecontrol/services"
+	"github.com/vincentcr/huecontrol/api/services"
+	"github.com/vincentcr/huecontrol/telemetry"
 )
 
 func main() {
@@ -20,13 +23,18 @@ func main() {
 }
 
 func setupServer(svc *services.Services) {
+	telemetry.SetExporter(telemetry.LogExporter{})
 	m := NewMux(svc)
 	setupMiddlewares(m)
+	routeMetrics(m)
 	routeUsers(m)
+	routeBridges(m)
+	routeBridgeControl(m)
 	m.Serve()
 }
 
 func setupMiddlewares(m *Mux) {
+	instrument()
 	m.Use(cors)
 	m.Use(authenticate)
 }
@@ -41,6 +49,32 @@ func cors(c *HCContext, w http.ResponseWriter, r *http.Request) error {
 type UserRequest struct {
 	Email    string `validate:"nonzero,regexp=^[A-Z0-9._%+-]+@[A-Z0-9.-]+\.[[:alnum:]]{2,}$"`
 	Password string `validate:"nonzero,min=6"`
+	Device   string `validate:"max=128"`
+}
+
+// TokenRequest creates a token; without scopes, it allows everything.
+type TokenRequest struct {
+	Device  string                 `validate:"max=128"`
+	Scopes  []services.Scope       `json:"scopes"`
+	Bridges []services.RecordID    `json:"bridges"`
+	Groups  []services.BridgeGroup `json:"groups"`
+}
+
+type PasswordResetRequest struct {
+	Email string `validate:"nonzero,max=256"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token    services.Token `validate:"nonzero,max=256"`
+	Password string         `validate:"nonzero,min=6"`
+}
+
+type VerifyRequest struct {
+	Token string `validate:"nonzero,max=512"`
+}
+
+type RefreshRequest struct {
+	RefreshToken services.Token `json:"refreshToken" validate:"nonzero,max=256"`
 }
 
 func routeUsers(m *Mux) {
@@ -58,38 +92,225 @@ func routeUsers(m *Mux) {
 			return err
 		}
 
-		token, err := c.Services.Users.CreateAccessToken(user)
+		if err := c.Services.Users.SendVerification(user); err != nil {
+			// the user can ask for it again
+			log.Printf("Unable to send verification mail to %v: %v", user, err)
+		}
+
+		tokens, err := c.Services.Users.CreateAccessToken(user, clientTokenOptions(c, r, userReq.Device))
 		if err != nil {
 			return err
 		}
 
-		res := map[string]interface{}{
-			"user":  user,
-			"token": token,
+		return jsonify(tokenResponse(user, tokens), w)
+	})
+
+	m.Post("/api/1.0.0/users/tokens", mustAllow(services.ScopeAccount, createToken))
+
+	// exchanges a refresh token for new tokens; each refresh token works once
+	m.Post("/api/1.0.0/users/tokens/refresh", func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		var refreshReq RefreshRequest
+		if err := parseAndValidate(r, &refreshReq); err != nil {
+			return err
+		}
+
+		user, tokens, err := c.Services.Users.RefreshAccessToken(refreshReq.RefreshToken, clientTokenOptions(c, r, ""))
+		if err == services.ErrNotFound || err == services.ErrRefreshTokenReused {
+			return NewHttpErrorWithText(http.StatusUnauthorized, "Invalid Refresh Token")
+		} else if err != nil {
+			return err
 		}
 
-		return jsonify(res, w)
+		return jsonify(tokenResponse(user, tokens), w)
 	})
 
-	m.Post("/api/1.0.0/users/tokens", mustAuthenticate(func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+	m.Get("/api/1.0.0/users/tokens", mustAllow(services.ScopeAccount, func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
 		user := c.MustGetUser()
-		token, err := c.Services.Users.CreateAccessToken(user)
+		current, _ := c.GetToken()
+		tokens, err := c.Services.Users.ListAccessTokens(user.ID, current)
 		if err != nil {
 			return err
 		}
+		return jsonify(tokens, w)
+	}))
 
-		res := map[string]interface{}{
-			"user":  user,
-			"token": token,
+	m.Delete("/api/1.0.0/users/tokens/current", mustAuthenticate(func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		user := c.MustGetUser()
+		current, ok := c.GetToken()
+		if !ok {
+			return HttpError{StatusCode: 400, StatusText: "Not authenticated with a token"}
+		}
+		if err := c.Services.Users.Logout(user.ID, current); err != nil {
+			return err
 		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
 
-		return jsonify(res, w)
+	m.Delete("/api/1.0.0/users/tokens/:id", mustAllow(services.ScopeAccount, func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		user := c.MustGetUser()
+		if err := c.Services.Users.RevokeAccessToken(user.ID, c.URLParams["id"]); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
 	}))
 
-	m.Get("/api/1.0.0/users/me", mustAuthenticate(func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+	m.Delete("/api/1.0.0/users/tokens", mustAllow(services.ScopeAccount, func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
 		user := c.MustGetUser()
+		if err := c.Services.Users.LogoutEverywhere(user.ID); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+
+	// mails a reset link if there is such a user; the response does not tell,
+	// but is throttled by address whether or not there is
+	m.Post("/api/1.0.0/users/password-reset", func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		var resetReq PasswordResetRequest
+		if err := parseAndValidate(r, &resetReq); err != nil {
+			return err
+		}
+
+		err := c.Services.Users.RequestPasswordReset(resetReq.Email)
+		if err == services.ErrRateLimited {
+			return NewHttpErrorWithText(http.StatusTooManyRequests, "Reset mail sent recently")
+		} else if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+
+	m.Post("/api/1.0.0/users/password-reset/confirm", func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		var confirmReq PasswordResetConfirmRequest
+		if err := parseAndValidate(r, &confirmReq); err != nil {
+			return err
+		}
+
+		err := c.Services.Users.ResetPassword(confirmReq.Token, confirmReq.Password)
+		if err == services.ErrNotFound {
+			return HttpError{StatusCode: 400, StatusText: "Invalid or expired reset token"}
+		} else if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+
+	m.Get("/api/1.0.0/users/me", mustAuthenticate(func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		// the user of a token is as it was at login: read it again for its verification
+		user, err := c.Services.Users.GetByID(c.MustGetUser().ID)
+		if err != nil {
+			return err
+		}
 		return jsonify(user, w)
 	}))
+
+	m.Post("/api/1.0.0/users/me/verification", mustAuthenticate(func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		user, err := c.Services.Users.GetByID(c.MustGetUser().ID)
+		if err != nil {
+			return err
+		}
+
+		err = c.Services.Users.SendVerification(user)
+		if err == services.ErrAlreadyVerified {
+			return HttpError{StatusCode: 400, StatusText: "Email address already verified"}
+		} else if err == services.ErrRateLimited {
+			return NewHttpErrorWithText(http.StatusTooManyRequests, "Verification mail sent recently")
+		} else if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+
+	m.Post("/api/1.0.0/users/verify", func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		var verifyReq VerifyRequest
+		if err := parseAndValidate(r, &verifyReq); err != nil {
+			return err
+		}
+
+		user, err := c.Services.Users.Verify(verifyReq.Token)
+		if err == services.ErrNotFound {
+			return HttpError{StatusCode: 400, StatusText: "Invalid or expired verification token"}
+		} else if err != nil {
+			return err
+		}
+		return jsonify(user, w)
+	})
+}
+
+// createToken logs in again, with at most the access of the request's token.
+func createToken(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+	user := c.MustGetUser()
+	var tokenReq TokenRequest
+	if r.ContentLength != 0 {
+		if err := parseAndValidate(r, &tokenReq); err != nil {
+			return err
+		}
+	}
+
+	access := services.Access{Scopes: tokenReq.Scopes, Bridges: tokenReq.Bridges, Groups: tokenReq.Groups}
+	if err := access.Validate(); err != nil {
+		return HttpError{StatusCode: 400, StatusText: err.Error()}
+	} else if !c.GetAccess().Covers(access) {
+		return NewHttpErrorWithText(http.StatusForbidden, "Token cannot grant more access than it has")
+	}
+
+	options := clientTokenOptions(c, r, tokenReq.Device)
+	options.Access = access
+	tokens, err := c.Services.Users.CreateAccessToken(user, options)
+	if err != nil {
+		return err
+	}
+
+	return jsonify(tokenResponse(user, tokens), w)
+}
+
+func tokenResponse(user services.User, tokens services.TokenPair) map[string]interface{} {
+	return map[string]interface{}{
+		"user":         user,
+		"token":        tokens.Token,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
+	}
+}
+
+// maxDeviceLength is the longest user agent kept as the device of a token.
+const maxDeviceLength = 128
+
+// clientTokenOptions describes the client logging in, for token listings. The
+// device defaults to the user agent.
+func clientTokenOptions(c *HCContext, r *http.Request, device string) services.TokenOptions {
+	if device == "" {
+		device = r.UserAgent()
+		if len(device) > maxDeviceLength {
+			device = device[:maxDeviceLength]
+		}
+	}
+	return services.TokenOptions{Device: device, IP: clientIP(c, r)}
+}
+
+// clientIP is the address the request came from. When that is one of our
+// trusted proxies, X-Forwarded-For is walked back to the first address that
+// is not, since anything before it could have been made up by the client.
+func clientIP(c *HCContext, r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0 && c.Services.TrustedProxies.Contains(net.ParseIP(ip)); i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+	}
+	return ip
 }
 
 func parseAndValidate(r *http.Request, result interface{}) error {
@@ -97,8 +318,6 @@ func parseAndValidate(r *http.Request, result interface{}) error {
 		return NewHttpError(http.StatusBadRequest)
 	}
 
-	fmt.Printf("request: %#v\n", result)
-
 	if err := validator.Validate(result); err != nil {
 		return NewHttpError(http.StatusBadRequest)
 	}
@@ -112,16 +331,23 @@ func parseBody(r *http.Request, result interface{}) error {
 }
 
 func jsonify(result interface{}, w http.ResponseWriter) error {
+	return jsonifyWithStatus(http.StatusOK, result, w)
+}
+
+func jsonifyWithStatus(status int, result interface{}, w http.ResponseWriter) error {
 	bytes, err := json.Marshal(result)
 	if err != nil {
 		return err
 	}
 
-	return writeAs(w, "application/json", bytes)
+	return writeAs(w, status, "application/json", bytes)
 }
 
-func writeAs(w http.ResponseWriter, contentType string, bytes []byte) error {
+// writeAs sets the content type before the status, after which headers can no
+// longer be changed.
+func writeAs(w http.ResponseWriter, status int, contentType string, bytes []byte) error {
 	w.Header().Set("content-type", contentType)
+	w.WriteHeader(status)
 	_, err := w.Write(bytes)
 
 	return err
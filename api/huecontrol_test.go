@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vincentcr/huecontrol/api/services"
+)
+
+func TestCreateTokenEscalation(t *testing.T) {
+	bridge := services.RecordID("86eb1856a155497aac7fd7ef50e7d2df")
+	tablet := services.Access{Bridges: []services.RecordID{bridge}, Groups: []services.BridgeGroup{{bridge, "1"}}}
+	tests := map[string]string{
+		"full access":   ``,
+		"account scope": `{"scopes": ["account"], "bridges": ["86eb1856a155497aac7fd7ef50e7d2df"], "groups": [{"bridge": "86eb1856a155497aac7fd7ef50e7d2df", "group": "1"}]}`,
+		"bridges admin": `{"scopes": ["bridges:admin"], "bridges": ["86eb1856a155497aac7fd7ef50e7d2df"], "groups": [{"bridge": "86eb1856a155497aac7fd7ef50e7d2df", "group": "1"}]}`,
+		"every bridge":  `{"scopes": ["lights:read"], "groups": [{"bridge": "86eb1856a155497aac7fd7ef50e7d2df", "group": "1"}]}`,
+		"another group": `{"scopes": ["lights:read"], "bridges": ["86eb1856a155497aac7fd7ef50e7d2df"], "groups": [{"bridge": "86eb1856a155497aac7fd7ef50e7d2df", "group": "2"}]}`,
+	}
+
+	for name, body := range tests {
+		c := &HCContext{}
+		c.Env = map[interface{}]interface{}{"user": services.User{ID: "86eb1856a155497aac7fd7ef50e7d2df"}, "access": tablet}
+		r, _ := http.NewRequest("POST", "/api/1.0.0/users/tokens", strings.NewReader(body))
+		err := createToken(c, httptest.NewRecorder(), r)
+		if httpErr, ok := err.(HttpError); assert.True(t, ok, name) {
+			assert.Equal(t, http.StatusForbidden, httpErr.StatusCode, name)
+		}
+	}
+
+	c := &HCContext{}
+	c.Env = map[interface{}]interface{}{"user": services.User{ID: "86eb1856a155497aac7fd7ef50e7d2df"}}
+	r, _ := http.NewRequest("POST", "/api/1.0.0/users/tokens", strings.NewReader(`{"scopes": ["everything"]}`))
+	err := createToken(c, httptest.NewRecorder(), r)
+	if httpErr, ok := err.(HttpError); assert.True(t, ok) {
+		assert.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := services.Networks{{IP: net.IPv4(10, 0, 0, 1).To4(), Mask: net.CIDRMask(32, 32)}}
+	c := &HCContext{Services: &services.Services{TrustedProxies: proxies}}
+	tests := []struct {
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{"203.0.113.7:4000", "", "203.0.113.7"},
+		{"203.0.113.7:4000", "198.51.100.1", "203.0.113.7"},
+		{"10.0.0.1:4000", "", "10.0.0.1"},
+		{"10.0.0.1:4000", "198.51.100.1", "198.51.100.1"},
+		{"10.0.0.1:4000", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"10.0.0.1:4000", "198.51.100.1, 10.0.0.1", "198.51.100.1"},
+		{"10.0.0.1:4000", "garbage", "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		assert.Equal(t, test.expected, clientIP(c, r), "%v %v", test.remoteAddr, test.forwarded)
+	}
+}
+
+func TestJsonifyWithStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	assert.Nil(t, jsonifyWithStatus(http.StatusCreated, map[string]string{"id": "1"}, w))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("content-type"))
+	assert.Equal(t, `{"id":"1"}`, w.Body.String())
+}
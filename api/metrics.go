@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vincentcr/huecontrol/telemetry"
+	"github.com/zenazn/goji"
+	"github.com/zenazn/goji/web"
+	"github.com/zenazn/goji/web/mutil"
+)
+
+const routeEnvKey = "route"
+
+var (
+	httpRequestsTotal = telemetry.DefaultRegistry.NewCounter(
+		"api_http_requests_total",
+		"Requests handled by the API server, by route and status code.",
+		"method", "route", "status")
+
+	httpRequestDuration = telemetry.DefaultRegistry.NewHistogram(
+		"api_http_request_duration_seconds",
+		"Latency of requests handled by the API server, including calls to bridges.",
+		telemetry.DefaultBuckets,
+		"method", "route")
+)
+
+// instrument records metrics for every request and starts the span that calls
+// to bridges made while handling it are traced under. It must be installed
+// before any middleware that may reject a request.
+func instrument() {
+	goji.Use(func(c *web.C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c.Env == nil {
+				c.Env = map[interface{}]interface{}{}
+			}
+			start := time.Now()
+			ctx, span := telemetry.StartSpanFromRequest(r, "api "+r.Method)
+			ww := mutil.WrapWriter(w)
+
+			h.ServeHTTP(ww, r.WithContext(ctx))
+
+			route, ok := c.Env[routeEnvKey].(string)
+			if !ok {
+				route = "unmatched"
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttribute("route", route)
+			span.SetAttribute("status", strconv.Itoa(status))
+			span.Finish()
+
+			httpRequestsTotal.Inc(r.Method, route, strconv.Itoa(status))
+			httpRequestDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+		})
+	})
+}
+
+func routeMetrics(m *Mux) {
+	m.Get("/metrics", func(c *HCContext, w http.ResponseWriter, r *http.Request) error {
+		telemetry.DefaultRegistry.Handler().ServeHTTP(w, r)
+		return nil
+	})
+}
@@ -6,7 +6,7 @@ import (
 	"net/http"
 	"runtime/debug"
 
-	"github.com/vincentcr/huecontrol/services"
+	"github.com/vincentcr/huecontrol/api/services"
 	"github.com/zenazn/goji"
 	"github.com/zenazn/goji/web"
 )
@@ -37,6 +37,19 @@ func (c *HCContext) MustGetUser() services.User {
 	return user
 }
 
+// GetToken returns the token the request was authenticated with, if any.
+func (c *HCContext) GetToken() (services.Token, bool) {
+	token, ok := c.Env["token"].(services.Token)
+	return token, ok
+}
+
+// GetAccess returns what the token of the request allows; requests
+// authenticated with a password may do anything.
+func (c *HCContext) GetAccess() services.Access {
+	access, _ := c.Env["access"].(services.Access)
+	return access
+}
+
 type middleware func(c *HCContext, w http.ResponseWriter, r *http.Request) error
 type handler func(c *HCContext, w http.ResponseWriter, r *http.Request) error
 
@@ -70,53 +83,56 @@ func (mux *Mux) Use(m middleware) {
 
 func (mux *Mux) Delete(pattern web.PatternType, h handler) {
 	goji.Delete(pattern, func(c web.C, w http.ResponseWriter, r *http.Request) {
-		mux.handleRequest(c, w, r, h)
+		mux.handleRequest(pattern, c, w, r, h)
 	})
 }
 
 func (mux *Mux) Head(pattern web.PatternType, h handler) {
 	goji.Head(pattern, func(c web.C, w http.ResponseWriter, r *http.Request) {
-		mux.handleRequest(c, w, r, h)
+		mux.handleRequest(pattern, c, w, r, h)
 	})
 }
 
 func (mux *Mux) Get(pattern web.PatternType, h handler) {
 	goji.Get(pattern, func(c web.C, w http.ResponseWriter, r *http.Request) {
-		mux.handleRequest(c, w, r, h)
+		mux.handleRequest(pattern, c, w, r, h)
 	})
 }
 
 func (mux *Mux) Options(pattern web.PatternType, h handler) {
 	goji.Options(pattern, func(c web.C, w http.ResponseWriter, r *http.Request) {
-		mux.handleRequest(c, w, r, h)
+		mux.handleRequest(pattern, c, w, r, h)
 	})
 }
 
 func (mux *Mux) Patch(pattern web.PatternType, h handler) {
 	goji.Patch(pattern, func(c web.C, w http.ResponseWriter, r *http.Request) {
-		mux.handleRequest(c, w, r, h)
+		mux.handleRequest(pattern, c, w, r, h)
 	})
 }
 
 func (mux *Mux) Post(pattern web.PatternType, h handler) {
 	goji.Post(pattern, func(c web.C, w http.ResponseWriter, r *http.Request) {
-		mux.handleRequest(c, w, r, h)
+		mux.handleRequest(pattern, c, w, r, h)
 	})
 }
 
 func (mux *Mux) Put(pattern web.PatternType, h handler) {
 	goji.Put(pattern, func(c web.C, w http.ResponseWriter, r *http.Request) {
-		mux.handleRequest(c, w, r, h)
+		mux.handleRequest(pattern, c, w, r, h)
 	})
 }
 
 func (mux *Mux) Trace(pattern web.PatternType, h handler) {
 	goji.Trace(pattern, func(c web.C, w http.ResponseWriter, r *http.Request) {
-		mux.handleRequest(c, w, r, h)
+		mux.handleRequest(pattern, c, w, r, h)
 	})
 }
 
-func (mux *Mux) handleRequest(c web.C, w http.ResponseWriter, r *http.Request, h handler) {
+func (mux *Mux) handleRequest(pattern web.PatternType, c web.C, w http.ResponseWriter, r *http.Request, h handler) {
+	if c.Env != nil {
+		c.Env[routeEnvKey] = fmt.Sprint(pattern)
+	}
 	sc := &HCContext{c, mux.svc}
 	err := h(sc, w, r)
 	if err != nil {
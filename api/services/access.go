@@ -0,0 +1,147 @@
+package services
+
+import "fmt"
+
+// Scope is a part of what a token may do.
+type Scope string
+
+const (
+	ScopeLightsRead   Scope = "lights:read"
+	ScopeLightsWrite  Scope = "lights:write"
+	ScopeBridgesAdmin Scope = "bridges:admin"
+	ScopeAccount      Scope = "account"
+)
+
+var Scopes = []Scope{ScopeLightsRead, ScopeLightsWrite, ScopeBridgesAdmin, ScopeAccount}
+
+var lightsScopes = []Scope{ScopeLightsRead, ScopeLightsWrite}
+
+// Access is what a token allows: the scopes it was given and, optionally, the
+// only bridges and groups it may control. The zero value, that of tokens
+// obtained with a password, allows everything; a token restricted to bridges
+// or groups without scopes only controls lights.
+type Access struct {
+	Scopes  []Scope       `json:"scopes,omitempty"`
+	Bridges []RecordID    `json:"bridges,omitempty"`
+	Groups  []BridgeGroup `json:"groups,omitempty"`
+}
+
+// BridgeGroup is a group of one bridge; group IDs are only unique within a
+// bridge.
+type BridgeGroup struct {
+	Bridge RecordID `json:"bridge"`
+	Group  string   `json:"group"`
+}
+
+// InvalidAccessError is returned when creating a token with an unknown scope.
+type InvalidAccessError struct {
+	Scope Scope
+}
+
+func (err InvalidAccessError) Error() string {
+	return fmt.Sprintf("unknown scope %q", err.Scope)
+}
+
+func (access Access) Validate() error {
+	for _, scope := range access.Scopes {
+		known := false
+		for _, s := range Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			return InvalidAccessError{scope}
+		}
+	}
+	for _, group := range access.Groups {
+		if group.Bridge == "" || group.Group == "" {
+			return fmt.Errorf("groups need both a bridge and a group")
+		} else if !access.AllowsBridge(group.Bridge) {
+			return fmt.Errorf("group %v is on bridge %v, which the token does not allow", group.Group, group.Bridge)
+		}
+	}
+	return nil
+}
+
+// scopes are those the token was given, or else its default ones.
+func (access Access) scopes() []Scope {
+	if len(access.Scopes) > 0 {
+		return access.Scopes
+	} else if len(access.Bridges) > 0 || len(access.Groups) > 0 {
+		return lightsScopes
+	}
+	return Scopes
+}
+
+// Allows tells whether the token has the scope. Changing lights implies
+// seeing them.
+func (access Access) Allows(scope Scope) bool {
+	for _, s := range access.scopes() {
+		if s == scope || (s == ScopeLightsWrite && scope == ScopeLightsRead) {
+			return true
+		}
+	}
+	return false
+}
+
+func (access Access) AllowsBridge(id RecordID) bool {
+	if len(access.Bridges) == 0 {
+		return true
+	}
+	for _, bridgeID := range access.Bridges {
+		if normalizeID(string(bridgeID)) == normalizeID(string(id)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsGroup tells whether the token may control group id of the bridge.
+func (access Access) AllowsGroup(bridge RecordID, id string) bool {
+	if len(access.Groups) == 0 {
+		return true
+	}
+	for _, group := range access.Groups {
+		if group.Group == id && normalizeID(string(group.Bridge)) == normalizeID(string(bridge)) {
+			return true
+		}
+	}
+	return false
+}
+
+// RestrictedToGroups tells whether only the lights of some groups may be controlled.
+func (access Access) RestrictedToGroups() bool {
+	return len(access.Groups) > 0
+}
+
+// Covers tells whether other allows nothing more than access does, so that a
+// token with access may create a token with other.
+func (access Access) Covers(other Access) bool {
+	for _, scope := range other.scopes() {
+		if !access.Allows(scope) {
+			return false
+		}
+	}
+
+	if len(access.Bridges) > 0 {
+		if len(other.Bridges) == 0 {
+			return false
+		}
+		for _, id := range other.Bridges {
+			if !access.AllowsBridge(id) {
+				return false
+			}
+		}
+	}
+
+	if len(access.Groups) > 0 {
+		if len(other.Groups) == 0 {
+			return false
+		}
+		for _, group := range other.Groups {
+			if !access.AllowsGroup(group.Bridge, group.Group) {
+				return false
+			}
+		}
+	}
+	return true
+}
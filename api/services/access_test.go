@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessAllows(t *testing.T) {
+	assert.True(t, Access{}.Allows(ScopeAccount))
+
+	access := Access{Scopes: []Scope{ScopeLightsWrite}}
+	assert.True(t, access.Allows(ScopeLightsWrite))
+	assert.True(t, access.Allows(ScopeLightsRead))
+	assert.False(t, access.Allows(ScopeBridgesAdmin))
+	assert.False(t, access.Allows(ScopeAccount))
+
+	access = Access{Scopes: []Scope{ScopeLightsRead}}
+	assert.False(t, access.Allows(ScopeLightsWrite))
+
+	// restricted tokens without scopes only control lights
+	access = Access{Groups: []BridgeGroup{{newID(), "1"}}}
+	assert.True(t, access.Allows(ScopeLightsWrite))
+	assert.False(t, access.Allows(ScopeBridgesAdmin))
+	assert.False(t, access.Allows(ScopeAccount))
+	access = Access{Bridges: []RecordID{newID()}}
+	assert.False(t, access.Allows(ScopeAccount))
+}
+
+func TestAccessCovers(t *testing.T) {
+	bridge, other := newID(), newID()
+	group := []BridgeGroup{{bridge, "1"}}
+	assert.True(t, Access{}.Covers(Access{}))
+	assert.True(t, Access{}.Covers(Access{Scopes: []Scope{ScopeAccount}, Groups: group}))
+
+	caller := Access{Scopes: []Scope{ScopeAccount, ScopeLightsRead}, Bridges: []RecordID{bridge, other}, Groups: group}
+	tests := map[string]struct {
+		access   Access
+		expected bool
+	}{
+		"same access":              {caller, true},
+		"fewer scopes":             {Access{Scopes: []Scope{ScopeLightsRead}, Bridges: []RecordID{bridge}, Groups: group}, true},
+		"default scopes":           {Access{Bridges: []RecordID{bridge}, Groups: group}, false},
+		"all scopes":               {Access{}, false},
+		"more scopes":              {Access{Scopes: []Scope{ScopeBridgesAdmin}, Bridges: []RecordID{bridge}, Groups: group}, false},
+		"every bridge":             {Access{Scopes: []Scope{ScopeLightsRead}, Groups: group}, false},
+		"another bridge":           {Access{Scopes: []Scope{ScopeLightsRead}, Bridges: []RecordID{newID()}, Groups: group}, false},
+		"every group":              {Access{Scopes: []Scope{ScopeLightsRead}, Bridges: []RecordID{bridge}}, false},
+		"another group":            {Access{Scopes: []Scope{ScopeLightsRead}, Bridges: []RecordID{bridge}, Groups: []BridgeGroup{{bridge, "1"}, {bridge, "2"}}}, false},
+		"same group, other bridge": {Access{Scopes: []Scope{ScopeLightsRead}, Bridges: []RecordID{other}, Groups: []BridgeGroup{{other, "1"}}}, false},
+		"write from read":          {Access{Scopes: []Scope{ScopeLightsWrite}, Bridges: []RecordID{bridge}, Groups: group}, false},
+	}
+	for name, test := range tests {
+		assert.Equal(t, test.expected, caller.Covers(test.access), name)
+	}
+}
+
+func TestAccessBridgesAndGroups(t *testing.T) {
+	assert.True(t, Access{}.AllowsBridge(newID()))
+	assert.True(t, Access{}.AllowsGroup(newID(), "3"))
+	assert.False(t, Access{}.RestrictedToGroups())
+
+	bridge, other := newID(), newID()
+	access := Access{Bridges: []RecordID{bridge, other}, Groups: []BridgeGroup{{bridge, "1"}, {bridge, "2"}, {other, "3"}}}
+	assert.True(t, access.AllowsBridge(bridge))
+	assert.False(t, access.AllowsBridge(newID()))
+	assert.True(t, access.AllowsGroup(bridge, "2"))
+	assert.False(t, access.AllowsGroup(bridge, "3"))
+	assert.True(t, access.AllowsGroup(other, "3"))
+	assert.False(t, access.AllowsGroup(other, "1"))
+	assert.True(t, access.RestrictedToGroups())
+}
+
+func TestAccessValidate(t *testing.T) {
+	bridge := newID()
+	assert.Nil(t, Access{}.Validate())
+	assert.Nil(t, Access{Scopes: Scopes}.Validate())
+	assert.Equal(t, InvalidAccessError{"admin"}, Access{Scopes: []Scope{ScopeLightsRead, "admin"}}.Validate())
+
+	assert.Nil(t, Access{Groups: []BridgeGroup{{bridge, "1"}}}.Validate())
+	assert.Nil(t, Access{Bridges: []RecordID{bridge}, Groups: []BridgeGroup{{bridge, "1"}}}.Validate())
+	assert.NotNil(t, Access{Groups: []BridgeGroup{{Group: "1"}}}.Validate())
+	assert.NotNil(t, Access{Groups: []BridgeGroup{{Bridge: bridge}}}.Validate())
+	assert.NotNil(t, Access{Bridges: []RecordID{newID()}, Groups: []BridgeGroup{{bridge, "1"}}}.Validate())
+}
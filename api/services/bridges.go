@@ -0,0 +1,242 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+// Bridge is a Hue bridge linked to a user account. Username is the whitelist
+// user created on the bridge, and RemoteToken the optional meethue token used
+// to reach it from outside the local network; neither is ever sent to clients.
+type Bridge struct {
+	ID          RecordID   `json:"id"`
+	UserID      RecordID   `json:"-"`
+	BridgeID    string     `json:"bridgeId"`
+	Name        string     `json:"name"`
+	Address     string     `json:"address"`
+	Username    string     `json:"-"`
+	RemoteToken string     `json:"-"`
+	VerifiedAt  *time.Time `json:"verifiedAt,omitempty"`
+}
+
+func (bridge Bridge) String() string {
+	return fmt.Sprintf("Bridge[%s, bridgeId:%s, address:%s]", bridge.ID, bridge.BridgeID, bridge.Address)
+}
+
+// Client returns a client talking to the bridge through the remote API if it
+// has a remote token, or else on its local network.
+func (bridge Bridge) Client() *hue.Client {
+	if bridge.RemoteToken != "" {
+		return NewRemoteAPI(bridge.RemoteToken, bridge.BridgeID).Client()
+	}
+	return hue.New(bridge.Address, bridge.Username)
+}
+
+// ErrAddressNotAllowed is returned for bridges whose address is not an IP in
+// the configured bridge networks, so that users cannot make us send requests
+// to arbitrary hosts.
+var ErrAddressNotAllowed = fmt.Errorf("address_not_allowed")
+
+type Bridges struct {
+	config Config
+	db     *sql.DB
+}
+
+func newBridges(config Config, db *sql.DB) (*Bridges, error) {
+	return &Bridges{config, db}, nil
+}
+
+const bridgeColumns = "id,user_id,bridge_id,name,address,username,remote_token,verified_at"
+
+func (bridges *Bridges) Add(userID RecordID, bridge Bridge) (Bridge, error) {
+	if !bridges.allowsAddress(bridge.Address) {
+		return Bridge{}, ErrAddressNotAllowed
+	}
+	bridge.ID = newID()
+	bridge.UserID = userID
+	bridge.BridgeID = normalizeBridgeID(bridge.BridgeID)
+	bridge.VerifiedAt = nil
+
+	_, err := bridges.db.Exec(
+		"INSERT INTO bridges(id,user_id,bridge_id,name,address,username,remote_token) VALUES($1,$2,$3,$4,$5,$6,$7)",
+		bridge.ID, bridge.UserID, bridge.BridgeID, bridge.Name, bridge.Address, bridge.Username, nullString(bridge.RemoteToken))
+	if err != nil {
+		if isUniqueError(err) {
+			return Bridge{}, ErrUniqueViolation
+		} else {
+			return Bridge{}, fmt.Errorf("unable to add bridge %v: %v", bridge, err)
+		}
+	}
+
+	return bridge, nil
+}
+
+func (bridges *Bridges) List(userID RecordID) ([]Bridge, error) {
+	rows, err := bridges.db.Query("SELECT "+bridgeColumns+" FROM bridges WHERE user_id=$1 ORDER BY name", userID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list bridges of user %v: %v", userID, err)
+	}
+	defer rows.Close()
+
+	result := make([]Bridge, 0)
+	for rows.Next() {
+		bridge, err := scanBridge(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list bridges of user %v: %v", userID, err)
+		}
+		result = append(result, bridge)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to list bridges of user %v: %v", userID, err)
+	}
+	return result, nil
+}
+
+// Get returns ErrNotFound if the bridge does not exist or belongs to another user.
+func (bridges *Bridges) Get(userID RecordID, id RecordID) (Bridge, error) {
+	if !isValidID(id) {
+		return Bridge{}, ErrNotFound
+	}
+	row := bridges.db.QueryRow("SELECT "+bridgeColumns+" FROM bridges WHERE id=$1 AND user_id=$2", id, userID)
+	bridge, err := scanBridge(row)
+	if err == sql.ErrNoRows {
+		return Bridge{}, ErrNotFound
+	} else if err != nil {
+		return Bridge{}, fmt.Errorf("Error fetching bridge %v: %v", id, err)
+	} else {
+		return bridge, nil
+	}
+}
+
+func (bridges *Bridges) Rename(userID RecordID, id RecordID, name string) error {
+	return bridges.update(userID, id, "UPDATE bridges SET name=$3 WHERE id=$1 AND user_id=$2", name)
+}
+
+func (bridges *Bridges) Remove(userID RecordID, id RecordID) error {
+	return bridges.update(userID, id, "DELETE FROM bridges WHERE id=$1 AND user_id=$2")
+}
+
+// VerificationError is returned by Verify when the bridge cannot be used.
+type VerificationError struct {
+	Reason string
+}
+
+func (err VerificationError) Error() string {
+	return err.Reason
+}
+
+// Verify checks that the bridge answers at its address with the expected ID and
+// accepts its whitelist user, and records when it did.
+func (bridges *Bridges) Verify(userID RecordID, id RecordID) (Bridge, error) {
+	bridge, err := bridges.Get(userID, id)
+	if err != nil {
+		return Bridge{}, err
+	}
+
+	if err := bridges.verify(bridge); err != nil {
+		return Bridge{}, err
+	}
+
+	now := time.Now().UTC()
+	if err := bridges.update(userID, id, "UPDATE bridges SET verified_at=$3 WHERE id=$1 AND user_id=$2", now); err != nil {
+		return Bridge{}, err
+	}
+	bridge.VerifiedAt = &now
+	return bridge, nil
+}
+
+func (bridges *Bridges) update(userID RecordID, id RecordID, query string, args ...interface{}) error {
+	if !isValidID(id) {
+		return ErrNotFound
+	}
+	res, err := bridges.db.Exec(query, append([]interface{}{id, userID}, args...)...)
+	if err != nil {
+		return fmt.Errorf("unable to update bridge %v: %v", id, err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to update bridge %v: %v", id, err)
+	} else if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Client returns a client for the bridge, or ErrAddressNotAllowed if it would
+// talk to an address outside the bridge networks.
+func (bridges *Bridges) Client(bridge Bridge) (*hue.Client, error) {
+	if bridge.RemoteToken == "" && !bridges.allowsAddress(bridge.Address) {
+		return nil, ErrAddressNotAllowed
+	}
+	return bridge.Client(), nil
+}
+
+// allowsAddress tells whether address, with an optional port, is an IP in the
+// bridge networks. Host names are refused, since they could later resolve to
+// anything.
+func (bridges *Bridges) allowsAddress(address string) bool {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && bridges.config.BridgeNetworks.Contains(ip)
+}
+
+func (bridges *Bridges) verify(bridge Bridge) error {
+	if bridge.RemoteToken != "" {
+		if _, err := bridge.Client().GetLights(); err != nil {
+			return VerificationError{fmt.Sprintf("the remote API does not give access to bridge %v", bridge.BridgeID)}
+		}
+		return nil
+	}
+
+	if !bridges.allowsAddress(bridge.Address) {
+		return VerificationError{fmt.Sprintf("%v is not an address on the local network", bridge.Address)}
+	}
+	config, err := hue.GetPublicConfig(bridge.Address)
+	if err != nil {
+		return VerificationError{fmt.Sprintf("no bridge answers at %v", bridge.Address)}
+	} else if normalizeBridgeID(config.BridgeID) != normalizeBridgeID(bridge.BridgeID) {
+		return VerificationError{fmt.Sprintf("the bridge at %v is %v, not %v", bridge.Address, config.BridgeID, bridge.BridgeID)}
+	}
+
+	if _, err := bridge.Client().GetLights(); err != nil {
+		return VerificationError{fmt.Sprintf("the bridge at %v does not accept the username", bridge.Address)}
+	}
+	return nil
+}
+
+func normalizeBridgeID(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBridge(row rowScanner) (Bridge, error) {
+	var bridge Bridge
+	var remoteToken sql.NullString
+	var verifiedAt pq.NullTime
+	err := row.Scan(&bridge.ID, &bridge.UserID, &bridge.BridgeID, &bridge.Name, &bridge.Address,
+		&bridge.Username, &remoteToken, &verifiedAt)
+	if err != nil {
+		return Bridge{}, err
+	}
+	bridge.RemoteToken = remoteToken.String
+	if verifiedAt.Valid {
+		bridge.VerifiedAt = &verifiedAt.Time
+	}
+	return bridge, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
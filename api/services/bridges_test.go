@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var bridgesTestSvc *Services
+
+func setupBridgesTest(t *testing.T) (*Services, User) {
+	testSuiteSetup(&bridgesTestSvc)
+
+	user, err := bridgesTestSvc.Users.Create(randEmail(), randWord(16))
+	assert.Nil(t, err)
+	return bridgesTestSvc, user
+}
+
+func mockBridge() Bridge {
+	return Bridge{
+		BridgeID: strings.ToLower(randString(16, alphanum)),
+		Name:     randWord(8),
+		Address:  fmt.Sprintf("192.168.1.%d", rand.Intn(254)+1),
+		Username: randString(40, alphanum),
+	}
+}
+
+func TestBridgesAddAndGet(t *testing.T) {
+	svc, user := setupBridgesTest(t)
+	bridge := mockBridge()
+	bridge.RemoteToken = randString(32, alphanum)
+
+	added, err := svc.Bridges.Add(user.ID, bridge)
+	assert.Nil(t, err)
+	assert.Len(t, added.ID, 32)
+	assert.Equal(t, user.ID, added.UserID)
+
+	found, err := svc.Bridges.Get(user.ID, added.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, added, found)
+
+	_, err = svc.Bridges.Add(user.ID, bridge)
+	assert.Equal(t, ErrUniqueViolation, err)
+
+	bridge.Address = "169.254.169.254"
+	_, err = svc.Bridges.Add(user.ID, bridge)
+	assert.Equal(t, ErrAddressNotAllowed, err)
+}
+
+func TestBridgesList(t *testing.T) {
+	svc, user := setupBridgesTest(t)
+	_, other := setupBridgesTest(t)
+
+	for i := 0; i < 3; i++ {
+		_, err := svc.Bridges.Add(user.ID, mockBridge())
+		assert.Nil(t, err)
+	}
+	_, err := svc.Bridges.Add(other.ID, mockBridge())
+	assert.Nil(t, err)
+
+	bridges, err := svc.Bridges.List(user.ID)
+	assert.Nil(t, err)
+	assert.Len(t, bridges, 3)
+	for _, bridge := range bridges {
+		assert.Equal(t, user.ID, bridge.UserID)
+	}
+}
+
+func TestBridgesRenameAndRemove(t *testing.T) {
+	svc, user := setupBridgesTest(t)
+	_, other := setupBridgesTest(t)
+	bridge, err := svc.Bridges.Add(user.ID, mockBridge())
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrNotFound, svc.Bridges.Rename(other.ID, bridge.ID, "hacked"))
+	assert.Nil(t, svc.Bridges.Rename(user.ID, bridge.ID, "upstairs"))
+	found, err := svc.Bridges.Get(user.ID, bridge.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "upstairs", found.Name)
+
+	assert.Equal(t, ErrNotFound, svc.Bridges.Remove(other.ID, bridge.ID))
+	assert.Nil(t, svc.Bridges.Remove(user.ID, bridge.ID))
+	_, err = svc.Bridges.Get(user.ID, bridge.ID)
+	assert.Equal(t, ErrNotFound, err)
+
+	_, err = svc.Bridges.Get(user.ID, "not-an-id")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestBridgesVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/config":
+			w.Write([]byte(`{"name":"Philips hue","bridgeid":"001788FFFE123456"}`))
+		case "/api/good-user/lights":
+			w.Write([]byte(`{}`))
+		default:
+			w.Write([]byte(`[{"error":{"type":1,"address":"/","description":"unauthorized user"}}]`))
+		}
+	}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	bridges := &Bridges{config: Config{BridgeNetworks: mustParseNetworks("127.0.0.1")}}
+	bridge := Bridge{BridgeID: "001788fffe123456", Address: address, Username: "good-user"}
+	assert.Nil(t, bridges.verify(bridge))
+
+	tests := map[string]Bridge{
+		"does not accept the username": {BridgeID: "001788fffe123456", Address: address, Username: "bad-user"},
+		"not 001788fffe000000":         {BridgeID: "001788fffe000000", Address: address, Username: "good-user"},
+		"no bridge answers":            {BridgeID: "001788fffe123456", Address: "127.0.0.1:1", Username: "good-user"},
+		"not an address on the local":  {BridgeID: "001788fffe123456", Address: "169.254.169.254", Username: "good-user"},
+	}
+	for expected, bridge := range tests {
+		err := bridges.verify(bridge)
+		if assert.IsType(t, VerificationError{}, err, expected) {
+			assert.Contains(t, err.Error(), expected)
+		}
+	}
+}
+
+func TestBridgesAllowsAddress(t *testing.T) {
+	bridges := &Bridges{config: builtinConfig}
+	tests := map[string]bool{
+		"192.168.1.20":        true,
+		"192.168.1.20:8080":   true,
+		"10.1.2.3":            true,
+		"172.31.0.5":          true,
+		"[fd12::1]:80":        true,
+		"127.0.0.1":           false,
+		"localhost":           false,
+		"169.254.169.254":     false,
+		"172.32.0.1":          false,
+		"8.8.8.8":             false,
+		"[::1]:80":            false,
+		"[fe80::1]":           false,
+		"0.0.0.0":             false,
+		"bridge.example.com":  false,
+		"192.168.1.20.nip.io": false,
+		"":                    false,
+	}
+	for address, expected := range tests {
+		assert.Equal(t, expected, bridges.allowsAddress(address), address)
+	}
+
+	_, err := bridges.Client(Bridge{Address: "169.254.169.254", Username: "user"})
+	assert.Equal(t, ErrAddressNotAllowed, err)
+	client, err := bridges.Client(Bridge{Address: "169.254.169.254", RemoteToken: "token", BridgeID: "001788fffe123456"})
+	assert.Nil(t, err)
+	assert.NotNil(t, client)
+}
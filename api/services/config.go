@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"path"
+	"regexp"
+	"time"
 
 	"github.com/imdario/mergo"
 	"github.com/kardianos/osext"
@@ -17,10 +20,111 @@ const (
 	defaultConfigKey      = "default"
 )
 
+// Config is read from config.json. Without an SMTPAddr, mail is written to
+// MailLogPath, or to the log. UnverifiedScopes are what accounts whose email is
+// not verified may do; an empty list allows them nothing. X-Forwarded-For is only believed when sent by one of
+// the TrustedProxies. Bridge addresses must be in BridgeNetworks, by default
+// the private LAN ranges.
 type Config struct {
-	PublicURL   string
-	PostgresURL string
-	RedisURL    string
+	PublicURL             string
+	PostgresURL           string
+	RedisURL              string
+	AccessTokenLifetime   Duration
+	RefreshTokenLifetime  Duration
+	PasswordResetLifetime Duration
+	VerificationLifetime  Duration
+	VerificationSecret    string
+	UnverifiedScopes      *[]Scope
+	MailFrom              string
+	SMTPAddr              string
+	SMTPUsername          string
+	SMTPPassword          string
+	MailLogPath           string
+	TrustedProxies        Networks
+	BridgeNetworks        Networks
+}
+
+// builtinConfig applies to every env, under what the config file sets.
+var builtinConfig = Config{
+	AccessTokenLifetime:   Duration(15 * time.Minute),
+	RefreshTokenLifetime:  Duration(30 * 24 * time.Hour),
+	PasswordResetLifetime: Duration(time.Hour),
+	VerificationLifetime:  Duration(72 * time.Hour),
+	UnverifiedScopes:      &[]Scope{ScopeLightsRead, ScopeLightsWrite, ScopeAccount},
+	MailFrom:              "noreply@localhost",
+	BridgeNetworks:        mustParseNetworks("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"),
+}
+
+// Duration is a time.Duration written as a string such as "15m" in config files.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s: %v", data, err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s: %v", data, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Networks are IP ranges written as CIDRs, or single addresses, in config files.
+type Networks []*net.IPNet
+
+func (networks *Networks) UnmarshalJSON(data []byte) error {
+	var addrs []string
+	if err := json.Unmarshal(data, &addrs); err != nil {
+		return fmt.Errorf("invalid networks %s: %v", data, err)
+	}
+	parsed := Networks{}
+	for _, addr := range addrs {
+		network, err := parseNetwork(addr)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, network)
+	}
+	*networks = parsed
+	return nil
+}
+
+func mustParseNetworks(addrs ...string) Networks {
+	networks := Networks{}
+	for _, addr := range addrs {
+		network, err := parseNetwork(addr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func parseNetwork(addr string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(addr); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid network %q", addr)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Contains tells whether ip is in one of the networks.
+func (networks Networks) Contains(ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
 func loadConfig(env string) (Config, error) {
@@ -83,16 +187,62 @@ func loadConfigFromFile(env string, path string) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	if err := config.validate(); err != nil {
+		return Config{}, fmt.Errorf("Invalid config for env '%v' at path '%v': %v", env, path, err)
+	}
 
 	return config, err
 }
 
+func (config Config) validate() error {
+	if config.VerificationSecret == "" {
+		return fmt.Errorf("verificationSecret must be set")
+	}
+	if config.UnverifiedScopes == nil {
+		return fmt.Errorf("unverifiedScopes must be set")
+	} else if err := (Access{Scopes: *config.UnverifiedScopes}).Validate(); err != nil {
+		return fmt.Errorf("unverifiedScopes: %v", err)
+	}
+	return nil
+}
+
+var postgresPasswordRegexp = regexp.MustCompile(`password=\S+`)
+
+// redacted is the config without its secrets, for logging.
+func (config Config) redacted() Config {
+	if config.SMTPPassword != "" {
+		config.SMTPPassword = "[redacted]"
+	}
+	if config.VerificationSecret != "" {
+		config.VerificationSecret = "[redacted]"
+	}
+	config.PostgresURL = postgresPasswordRegexp.ReplaceAllString(config.PostgresURL, "password=[redacted]")
+	return config
+}
+
 func mergeConfig(env string, configs map[string]Config) (Config, error) {
 	config := configs[env]
 	defaultConfig := configs["default"]
+	builtin := builtinConfig
+
+	// mergo takes an empty list for an unset one, and would merge the
+	// lists pointed to, so the first config setting the scopes is kept as is.
+	unverifiedScopes := config.UnverifiedScopes
+	for _, other := range []*Config{&defaultConfig, &builtin} {
+		if unverifiedScopes == nil {
+			unverifiedScopes = other.UnverifiedScopes
+		}
+		other.UnverifiedScopes = nil
+	}
+	config.UnverifiedScopes = nil
+
 	if err := mergo.Merge(&config, defaultConfig); err != nil {
 		return config, fmt.Errorf("Failed to merge config for env '%v', %#v: %v", env, configs, err)
 	}
+	if err := mergo.Merge(&config, builtin); err != nil {
+		return config, fmt.Errorf("Failed to merge config for env '%v', %#v: %v", env, configs, err)
+	}
+	config.UnverifiedScopes = unverifiedScopes
 
 	return config, nil
 }
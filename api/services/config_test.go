@@ -0,0 +1,95 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeConfigLifetimes(t *testing.T) {
+	var configs map[string]Config
+	err := json.Unmarshal([]byte(`{
+		"default": {"redisURL": "localhost:6379", "refreshTokenLifetime": "48h"},
+		"test": {"accessTokenLifetime": "5m"}
+	}`), &configs)
+	assert.Nil(t, err)
+
+	config, err := mergeConfig("test", configs)
+	assert.Nil(t, err)
+	assert.Equal(t, Duration(5*time.Minute), config.AccessTokenLifetime)
+	assert.Equal(t, Duration(48*time.Hour), config.RefreshTokenLifetime)
+
+	config, err = mergeConfig("dev", configs)
+	assert.Nil(t, err)
+	assert.Equal(t, builtinConfig.AccessTokenLifetime, config.AccessTokenLifetime)
+
+	err = json.Unmarshal([]byte(`{"test": {"accessTokenLifetime": "soon"}}`), &configs)
+	assert.NotNil(t, err)
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := builtinConfig
+	assert.NotNil(t, config.validate(), "no verification secret")
+
+	config.VerificationSecret = "secret"
+	assert.Nil(t, config.validate())
+
+	config.UnverifiedScopes = &[]Scope{"everything"}
+	assert.NotNil(t, config.validate())
+}
+
+func TestConfigNetworks(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{"trustedProxies": ["10.0.0.0/8", "192.168.1.1", "::1"]}`), &config)
+	assert.Nil(t, err)
+	assert.True(t, config.TrustedProxies.Contains(net.ParseIP("10.1.2.3")))
+	assert.True(t, config.TrustedProxies.Contains(net.ParseIP("192.168.1.1")))
+	assert.True(t, config.TrustedProxies.Contains(net.ParseIP("::1")))
+	assert.False(t, config.TrustedProxies.Contains(net.ParseIP("192.168.1.2")))
+	assert.False(t, config.TrustedProxies.Contains(nil))
+
+	err = json.Unmarshal([]byte(`{"trustedProxies": ["proxy.local"]}`), &config)
+	assert.NotNil(t, err)
+}
+
+func TestConfigRedacted(t *testing.T) {
+	config := Config{
+		PostgresURL:        "dbname=huecontrol user=huecontrol password=db-secret sslmode=disable",
+		VerificationSecret: "hmac-secret",
+		SMTPUsername:       "mailer",
+		SMTPPassword:       "smtp-secret",
+	}
+	printed := fmt.Sprintf("%#v", config.redacted())
+	for _, secret := range []string{"db-secret", "hmac-secret", "smtp-secret"} {
+		assert.NotContains(t, printed, secret)
+	}
+	assert.Contains(t, printed, "dbname=huecontrol user=huecontrol password=[redacted] sslmode=disable")
+	assert.Contains(t, printed, "mailer")
+	assert.Equal(t, "hmac-secret", config.VerificationSecret)
+}
+
+func TestMergeConfigUnverifiedScopes(t *testing.T) {
+	var configs map[string]Config
+	err := json.Unmarshal([]byte(`{
+		"default": {"unverifiedScopes": ["lights:read"]},
+		"strict": {"unverifiedScopes": []}
+	}`), &configs)
+	assert.Nil(t, err)
+
+	config, err := mergeConfig("strict", configs)
+	assert.Nil(t, err)
+	assert.Equal(t, &[]Scope{}, config.UnverifiedScopes)
+
+	config, err = mergeConfig("dev", configs)
+	assert.Nil(t, err)
+	assert.Equal(t, &[]Scope{ScopeLightsRead}, config.UnverifiedScopes)
+
+	config, err = mergeConfig("dev", map[string]Config{})
+	assert.Nil(t, err)
+	assert.Equal(t, builtinConfig.UnverifiedScopes, config.UnverifiedScopes)
+	assert.Equal(t, &[]Scope{ScopeLightsRead, ScopeLightsWrite, ScopeAccount}, builtinConfig.UnverifiedScopes)
+}
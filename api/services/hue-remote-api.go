@@ -1,18 +1,25 @@
 package services
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"regexp"
+	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/vincentcr/huecontrol/hue"
 )
 
 const BASE_URL = "https://www.meethue.com"
 
+var ErrInvalidHueCredentials = fmt.Errorf("Unable to grant access to bridge. Please verify your username and password")
+
 type HueTokenCredentials struct {
 	DeviceID string
 	Username string
@@ -41,52 +48,186 @@ func GetTokenAtBaseURL(baseURL string, creds HueTokenCredentials) (string, error
 	return fetchToken(hueClient, tokenURL)
 }
 
-func SendMessage(token string) {
+// RemoteAPI reaches a bridge through the meethue remote API, with a token
+// obtained by GetToken.
+type RemoteAPI struct {
+	baseURL  string
+	token    string
+	bridgeID string
+	http     http.Client
+}
+
+type remoteResult struct {
+	Code    int
+	Message string
+	Result  string
+}
+
+func NewRemoteAPI(token string, bridgeID string) *RemoteAPI {
+	return NewRemoteAPIAtBaseURL(BASE_URL, token, bridgeID)
+}
+
+func NewRemoteAPIAtBaseURL(baseURL string, token string, bridgeID string) *RemoteAPI {
+	return &RemoteAPI{baseURL: baseURL, token: token, bridgeID: bridgeID}
+}
+
+// Client returns a hue client whose calls go through the remote API, so that
+// they return the same types as with a bridge on the local network. Changes
+// are sent without waiting for the bridge, so they cannot report its errors,
+// and creating resources is not supported.
+func (api *RemoteAPI) Client() *hue.Client {
+	return hue.NewWithTransport(api.bridgeID, "0", api)
+}
+
+// GetStatus returns the full state of the bridge: its config, lights, groups,
+// scenes, schedules, sensors and rules.
+func (api *RemoteAPI) GetStatus() ([]byte, error) {
+	query := url.Values{"token": {api.token}, "bridgeid": {api.bridgeID}}
+	resp, err := api.http.Get(fmt.Sprintf("%s/api/getbridge?%s", api.baseURL, query.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("GetStatus: request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := readRemoteResult(resp)
+	if err != nil {
+		return nil, fmt.Errorf("GetStatus: %v", err)
+	}
+	return body, nil
+}
+
+// SendMessage sends a request to the bridge, such as a PUT of a light state
+// to /api/0/lights/1/state.
+func (api *RemoteAPI) SendMessage(method string, path string, body json.RawMessage) error {
+	message := map[string]interface{}{
+		"bridgeId": api.bridgeID,
+		"clipCommand": map[string]interface{}{
+			"url":    path,
+			"method": method,
+			"body":   body,
+		},
+	}
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("SendMessage: unable to encode message %v: %v", message, err)
+	}
+
+	query := url.Values{"token": {api.token}}
+	form := url.Values{"clipmessage": {string(messageJSON)}}
+	resp, err := api.http.PostForm(fmt.Sprintf("%s/api/sendmessage?%s", api.baseURL, query.Encode()), form)
+	if err != nil {
+		return fmt.Errorf("SendMessage: request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := readRemoteResult(resp); err != nil {
+		return fmt.Errorf("SendMessage: %v", err)
+	}
+	return nil
+}
+
+func readRemoteResult(resp *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response: %v", err)
+	} else if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status code %v. Body: %s", resp.StatusCode, body)
+	}
+
+	var result remoteResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("unable to parse response %s: %v", body, err)
+	} else if result.Result == "error" {
+		return nil, fmt.Errorf("remote API error %v: %v", result.Code, result.Message)
+	}
+	return body, nil
 }
 
-type HueLight struct {
-	Name    string
-	UID     string `json:"uniqueid"`
-	ID      string
-	Type    string
-	ModelID string `json:"modelid"`
-	State   struct {
-		On         bool
-		Brightness int `json:"bri"`
-		Alert      string
-		Reachable  bool
+// RoundTrip translates the requests of the hue client: reads are answered
+// from the full state of the bridge, anything else is sent as a message.
+func (api *RemoteAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the path is /api/<username>/<resource>
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/api/"), "/", 2)
+	resource := "/"
+	if len(parts) == 2 {
+		resource += parts[1]
+	}
+
+	if req.Method == "GET" {
+		status, err := api.GetStatus()
+		if err != nil {
+			return nil, err
+		}
+		return remoteResponse(req, lookupResource(status, resource)), nil
 	}
+
+	var body json.RawMessage
+	if req.Body != nil {
+		defer req.Body.Close()
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = json.RawMessage(data)
+	}
+	if err := api.SendMessage(req.Method, "/api/0"+resource, body); err != nil {
+		return nil, err
+	}
+	return remoteResponse(req, []byte("[]")), nil
 }
 
-func GetStatus(token string) {
+// lookupResource returns the part of the full state at path, such as
+// /lights/1, or the error the bridge itself returns for unknown resources.
+func lookupResource(status []byte, path string) []byte {
+	current := json.RawMessage(status)
+	for _, key := range strings.Split(strings.Trim(path, "/"), "/") {
+		if key == "" {
+			continue
+		}
+		var children map[string]json.RawMessage
+		if err := json.Unmarshal(current, &children); err != nil {
+			return notAvailable(path)
+		}
+		child, found := children[key]
+		if !found {
+			return notAvailable(path)
+		}
+		current = child
+	}
+	return current
+}
 
+func notAvailable(path string) []byte {
+	errors := []map[string]interface{}{{
+		"error": map[string]interface{}{
+			"type":        3,
+			"address":     path,
+			"description": fmt.Sprintf("resource, %v, not available", path),
+		},
+	}}
+	body, _ := json.Marshal(errors)
+	return body
 }
 
-// func getBridgeID() (string, error) {
-// 	url := BASE_URL + "/api/nupnp"
-// 	resp, err := httpClient.Get(url)
-// 	if err != nil {
-// 		return "", fmt.Errorf("getBridgeID: request to %v failed: %v", url, err)
-// 	}
-// 	defer resp.Body.Close()
-// 	body, err := ioutil.ReadAll(resp.Body)
-// 	if err != nil {
-// 		return "", fmt.Errorf("getBridgeID: reading response body failed", err)
-// 	}
-//
-// 	var bridges []struct{ ID string }
-// 	err = json.Unmarshal(body, &bridges)
-// 	if err != nil {
-// 		return "", fmt.Errorf("getBridgeID: parsing response body failed", err)
-// 	}
-//
-// 	return bridges[0].ID, nil
-// }
+func remoteResponse(req *http.Request, body []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
 
 func startTokenSession(client *hueClient, bridgeID string) error {
-	resp, err := client.get("/en-us/api/gettoken?devicename=iPhone+5&appid=hueapp&deviceid=" + bridgeID)
+	query := url.Values{"devicename": {"iPhone 5"}, "appid": {"hueapp"}, "deviceid": {bridgeID}}
+	resp, err := client.get("/en-us/api/gettoken?" + query.Encode())
 	if err != nil {
-		return fmt.Errorf("starTokenSession failed: %v", err)
+		return fmt.Errorf("startTokenSession failed: %v", err)
 	}
 	defer resp.Body.Close()
 
@@ -103,12 +244,12 @@ func grantAccess(client *hueClient, email, password string) (string, error) {
 
 	gq, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("grantAccess: Unable to parse response", err)
+		return "", fmt.Errorf("grantAccess: Unable to parse response: %v", err)
 	}
 
 	tokenURL, found := gq.Find("[data-role='yes']").Attr("href")
 	if !found {
-		return "", fmt.Errorf("Invalid credentials")
+		return "", ErrInvalidHueCredentials
 	} else {
 		return tokenURL, nil
 	}
@@ -124,7 +265,7 @@ func fetchToken(hueClient *hueClient, tokenURL string) (string, error) {
 
 	gq, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("fetchToken: Unable to parse response", err)
+		return "", fmt.Errorf("fetchToken: Unable to parse response: %v", err)
 	}
 	successURL, found := gq.Find("a.button-primary").Attr("href")
 	if !found {
@@ -134,19 +275,24 @@ func fetchToken(hueClient *hueClient, tokenURL string) (string, error) {
 	tokenExtractRe := regexp.MustCompile("^phhueapp://sdk/login/(.+)$")
 	matches := tokenExtractRe.FindStringSubmatch(successURL)
 	if len(matches) == 0 {
-		return "", fmt.Errorf("Unable to parse token from link")
+		return "", fmt.Errorf("Unable to parse token from link %v", successURL)
 	}
 
 	return matches[1], nil
 }
 
 type hueClient struct {
-	baseURL string
+	baseURL *url.URL
 	http    http.Client
 }
 
 func newHueClient(baseURL string) (*hueClient, error) {
 	c := hueClient{}
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("newHueClient: invalid base url %v: %v", baseURL, err)
+	}
+	c.baseURL = parsed
 	c.http = http.Client{}
 	jar, err := cookiejar.New(nil)
 	if err != nil {
@@ -156,14 +302,46 @@ func newHueClient(baseURL string) (*hueClient, error) {
 	return &c, nil
 }
 
+// url resolves ref, which may be a path or, for links found in pages, a full url.
+func (c *hueClient) url(ref string) (string, error) {
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid url %v: %v", ref, err)
+	}
+	return c.baseURL.ResolveReference(parsed).String(), nil
+}
+
 func (c *hueClient) get(path string) (*http.Response, error) {
-	return c.http.Get(fmt.Sprintf("%s/%s", c.baseURL, path))
+	u, err := c.url(path)
+	if err != nil {
+		return nil, err
+	}
+	return checkStatus(c.http.Get(u))
 }
 
 func (c *hueClient) post(path string, bodyType string, body io.Reader) (*http.Response, error) {
-	return c.http.Post(fmt.Sprintf("%s/%s", c.baseURL, path), bodyType, body)
+	u, err := c.url(path)
+	if err != nil {
+		return nil, err
+	}
+	return checkStatus(c.http.Post(u, bodyType, body))
 }
 
 func (c *hueClient) postForm(path string, form url.Values) (*http.Response, error) {
-	return c.http.PostForm(fmt.Sprintf("%s/%s", c.baseURL, path), form)
+	u, err := c.url(path)
+	if err != nil {
+		return nil, err
+	}
+	return checkStatus(c.http.PostForm(u, form))
+}
+
+func checkStatus(resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%v: unexpected status %v. body: %s", resp.Request.URL, resp.StatusCode, body)
+	}
+	return resp, nil
 }
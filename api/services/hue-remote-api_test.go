@@ -0,0 +1,196 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+const remoteTestToken = "remote-token"
+const remoteTestBridgeID = "001788fffe123456"
+
+const remoteTestStatus = `{
+  "config": {"name": "Philips hue", "bridgeid": "001788FFFE123456"},
+  "lights": {
+    "1": {"name": "Desk", "type": "Extended color light", "state": {"on": true, "bri": 200, "colormode": "ct", "ct": 370}},
+    "2": {"name": "Kitchen", "type": "Dimmable light", "state": {"on": false, "bri": 10}}
+  },
+  "groups": {"1": {"name": "Living room", "type": "Room", "lights": ["1", "2"], "action": {"on": true}}},
+  "scenes": {"abc": {"name": "Relax", "type": "GroupScene", "group": "1", "lights": ["1", "2"]}}
+}`
+
+// startRemoteAPI stands in for the meethue remote API of a single bridge, and
+// records the messages sent to it.
+func startRemoteAPI(t *testing.T, messages *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("token") != remoteTestToken {
+			w.Write([]byte(`{"code":109,"message":"I don't know that token.","result":"error"}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/getbridge":
+			assert.Equal(t, remoteTestBridgeID, r.FormValue("bridgeid"))
+			w.Write([]byte(remoteTestStatus))
+		case "/api/sendmessage":
+			var message map[string]interface{}
+			assert.Nil(t, json.Unmarshal([]byte(r.PostFormValue("clipmessage")), &message))
+			*messages = append(*messages, message)
+			w.Write([]byte(`{"code":200,"message":"ok","result":"ok"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestRemoteAPIReads(t *testing.T) {
+	var messages []map[string]interface{}
+	server := startRemoteAPI(t, &messages)
+	defer server.Close()
+	client := NewRemoteAPIAtBaseURL(server.URL, remoteTestToken, remoteTestBridgeID).Client()
+
+	lights, err := client.GetLights()
+	assert.Nil(t, err)
+	if assert.Len(t, lights, 2) {
+		assert.Equal(t, "1", lights[0].ID)
+		assert.Equal(t, "Desk", lights[0].Name)
+		assert.Equal(t, uint16(370), lights[0].State.CT)
+		assert.Equal(t, "Kitchen", lights[1].Name)
+	}
+
+	group, err := client.GetGroup("1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Living room", group.Name)
+	assert.Equal(t, []string{"1", "2"}, group.Lights)
+
+	scenes, err := client.GetScenes()
+	assert.Nil(t, err)
+	if assert.Len(t, scenes, 1) {
+		assert.Equal(t, "Relax", scenes[0].Name)
+	}
+
+	_, err = client.GetLight("9")
+	assert.Equal(t, hue.Error{Type: hue.ErrCodesResourceNotAvailable, Address: "/lights/9", Description: "resource, /lights/9, not available"}, err)
+
+	assert.Empty(t, messages)
+}
+
+func TestRemoteAPIChanges(t *testing.T) {
+	var messages []map[string]interface{}
+	server := startRemoteAPI(t, &messages)
+	defer server.Close()
+	client := NewRemoteAPIAtBaseURL(server.URL, remoteTestToken, remoteTestBridgeID).Client()
+
+	on := true
+	assert.Nil(t, client.SetLightState("1", hue.StateUpdate{On: &on}))
+	assert.Nil(t, client.RecallScene("1", "abc"))
+
+	expected := []map[string]interface{}{
+		{
+			"bridgeId": remoteTestBridgeID,
+			"clipCommand": map[string]interface{}{
+				"url":    "/api/0/lights/1/state",
+				"method": "PUT",
+				"body":   map[string]interface{}{"on": true},
+			},
+		},
+		{
+			"bridgeId": remoteTestBridgeID,
+			"clipCommand": map[string]interface{}{
+				"url":    "/api/0/groups/1/action",
+				"method": "PUT",
+				"body":   map[string]interface{}{"scene": "abc"},
+			},
+		},
+	}
+	assert.Equal(t, expected, messages)
+}
+
+func TestRemoteAPIErrors(t *testing.T) {
+	var messages []map[string]interface{}
+	server := startRemoteAPI(t, &messages)
+	defer server.Close()
+	client := NewRemoteAPIAtBaseURL(server.URL, "wrong-token", remoteTestBridgeID).Client()
+
+	_, err := client.GetLights()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "I don't know that token.")
+	}
+	err = client.SetGroupState("1", hue.StateUpdate{Scene: "abc"})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "remote API error 109")
+	}
+	assert.Empty(t, messages)
+}
+
+// startMeethue stands in for the meethue login and grant pages. Each step
+// needs the session cookie set by the previous one.
+func startMeethue(t *testing.T, bridgeID, email, password, token string) *httptest.Server {
+	session := &http.Cookie{Name: "JSESSIONID", Value: "s3ss10n"}
+	hasSession := func(r *http.Request) bool {
+		cookie, err := r.Cookie(session.Name)
+		return err == nil && cookie.Value == session.Value
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/en-us/api/gettoken":
+			if r.FormValue("deviceid") != bridgeID || r.FormValue("appid") != "hueapp" {
+				http.Error(w, "unknown device", http.StatusBadRequest)
+				return
+			}
+			http.SetCookie(w, session)
+			fmt.Fprint(w, `<html><form action="/en-us/api/getaccesstokengivepermission" method="post"></form></html>`)
+		case "/en-us/api/getaccesstokengivepermission":
+			if !hasSession(r) || r.Method != "POST" {
+				http.Error(w, "no session", http.StatusForbidden)
+			} else if r.PostFormValue("email") != email || r.PostFormValue("password") != password {
+				fmt.Fprint(w, `<html><p class="error">Invalid email or password</p></html>`)
+			} else {
+				fmt.Fprint(w, `<html><a data-role="no" href="/en-us">No</a><a data-role="yes" href="/en-us/api/getaccesstokenpost?grant=1">Yes</a></html>`)
+			}
+		case "/en-us/api/getaccesstokenpost":
+			if !hasSession(r) || r.FormValue("grant") != "1" {
+				http.Error(w, "no session", http.StatusForbidden)
+				return
+			}
+			fmt.Fprintf(w, `<html><a class="button-primary" href="phhueapp://sdk/login/%s">Back to app</a></html>`, token)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestGetTokenAtBaseURL(t *testing.T) {
+	server := startMeethue(t, remoteTestBridgeID, "me@example.com", "s3cret", "dG9rZW4=")
+	defer server.Close()
+
+	token, err := GetTokenAtBaseURL(server.URL, HueTokenCredentials{
+		DeviceID: remoteTestBridgeID,
+		Username: "me@example.com",
+		Password: "s3cret",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "dG9rZW4=", token)
+
+	_, err = GetTokenAtBaseURL(server.URL, HueTokenCredentials{
+		DeviceID: remoteTestBridgeID,
+		Username: "me@example.com",
+		Password: "wrong",
+	})
+	assert.Equal(t, ErrInvalidHueCredentials, err)
+
+	_, err = GetTokenAtBaseURL(server.URL, HueTokenCredentials{
+		DeviceID: "001788fffe000000",
+		Username: "me@example.com",
+		Password: "s3cret",
+	})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unexpected status 400")
+	}
+}
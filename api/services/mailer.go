@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is a plain text mail.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// newMailer sends mail through the configured SMTP server, or else writes it
+// to the configured file or the log, for development.
+func newMailer(config Config) Mailer {
+	if config.SMTPAddr != "" {
+		return &SMTPMailer{
+			Addr:     config.SMTPAddr,
+			From:     config.MailFrom,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+		}
+	}
+	return &LogMailer{Path: config.MailLogPath, From: config.MailFrom}
+}
+
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func (mailer *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if mailer.Username != "" {
+		host, _, err := net.SplitHostPort(mailer.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address %v: %v", mailer.Addr, err)
+		}
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, host)
+	}
+
+	err := smtp.SendMail(mailer.Addr, auth, mailer.From, []string{msg.To}, formatMessage(mailer.From, msg))
+	if err != nil {
+		return fmt.Errorf("unable to send mail %q to %v through %v: %v", msg.Subject, msg.To, mailer.Addr, err)
+	}
+	return nil
+}
+
+// LogMailer appends mail to the file at Path, or to the log if there is none.
+type LogMailer struct {
+	Path string
+	From string
+}
+
+func (mailer *LogMailer) Send(msg Message) error {
+	if mailer.Path == "" {
+		log.Printf("Mail to %v:\n%s", msg.To, formatMessage(mailer.From, msg))
+		return nil
+	}
+
+	file, err := os.OpenFile(mailer.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open mail log %v: %v", mailer.Path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(formatMessage(mailer.From, msg), '\n')); err != nil {
+		return fmt.Errorf("unable to write to mail log %v: %v", mailer.Path, err)
+	}
+	return nil
+}
+
+func formatMessage(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", headerValue(msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&buf, "\r\n")
+	buf.WriteString(strings.Replace(msg.Body, "\n", "\r\n", -1))
+	return buf.Bytes()
+}
+
+// headerValue keeps a value on its header line.
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
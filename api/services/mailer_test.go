@@ -0,0 +1,101 @@
+package services
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startSMTP stands in for an SMTP server without extensions, and sends the
+// data of each mail it receives on the channel.
+func startSMTP(t *testing.T) (net.Listener, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mails := make(chan string, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 localhost fake smtp")
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var data []string
+				for {
+					line, err := rd.ReadString('\n')
+					if err != nil {
+						return
+					} else if line == ".\r\n" {
+						break
+					}
+					data = append(data, line)
+				}
+				mails <- strings.Join(data, "")
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener, mails
+}
+
+func TestSMTPMailer(t *testing.T) {
+	listener, mails := startSMTP(t)
+	defer listener.Close()
+
+	mailer := &SMTPMailer{Addr: listener.Addr().String(), From: "noreply@example.com"}
+	err := mailer.Send(Message{To: "me@example.com", Subject: "Hello\r\nBcc: evil@example.com", Body: "line 1\nline 2"})
+	assert.Nil(t, err)
+
+	mail := <-mails
+	assert.Contains(t, mail, "From: noreply@example.com\r\n")
+	assert.Contains(t, mail, "To: me@example.com\r\n")
+	assert.Contains(t, mail, "Subject: HelloBcc: evil@example.com\r\n")
+	assert.Contains(t, mail, "\r\n\r\nline 1\r\nline 2")
+}
+
+func TestLogMailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mailer")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	mailer := &LogMailer{Path: path.Join(dir, "mail.log"), From: "noreply@example.com"}
+	assert.Nil(t, mailer.Send(Message{To: "me@example.com", Subject: "first", Body: "1"}))
+	assert.Nil(t, mailer.Send(Message{To: "me@example.com", Subject: "second", Body: "2"}))
+
+	contents, err := ioutil.ReadFile(mailer.Path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(contents), "Subject: first\r\n")
+	assert.Contains(t, string(contents), "Subject: second\r\n")
+}
+
+func TestNewMailer(t *testing.T) {
+	assert.IsType(t, &LogMailer{}, newMailer(Config{}))
+	assert.IsType(t, &SMTPMailer{}, newMailer(Config{SMTPAddr: "localhost:25"}))
+}
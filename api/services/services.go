@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/satori/go.uuid"
@@ -12,9 +13,11 @@ import (
 )
 
 type Services struct {
-	Users *Users
-	db    *sql.DB
-	redis *redis.Client
+	Users          *Users
+	Bridges        *Bridges
+	TrustedProxies Networks
+	db             *sql.DB
+	redis          *redis.Client
 }
 
 var (
@@ -27,7 +30,7 @@ func New(env string) (*Services, error) {
 	if err != nil {
 		return nil, err
 	} else {
-		fmt.Printf("using config: %#v\n", config)
+		fmt.Printf("using config: %#v\n", config.redacted())
 	}
 
 	db, err := setupDB(config)
@@ -39,12 +42,17 @@ func New(env string) (*Services, error) {
 		return nil, err
 	}
 
-	users, err := newUsers(config, db, redisClient)
+	users, err := newUsers(config, db, redisClient, newMailer(config))
 	if err != nil {
 		return nil, err
 	}
 
-	svc := &Services{Users: users, db: db, redis: redisClient}
+	bridges, err := newBridges(config, db)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &Services{Users: users, Bridges: bridges, TrustedProxies: config.TrustedProxies, db: db, redis: redisClient}
 
 	return svc, nil
 }
@@ -99,6 +107,14 @@ func (id *RecordID) Scan(val interface{}) error {
 	return nil
 }
 
+var idRegexp = regexp.MustCompile("^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$")
+
+// isValidID tells whether id is a uuid, so that ids taken from URLs can be
+// rejected before reaching the database.
+func isValidID(id RecordID) bool {
+	return idRegexp.MatchString(string(id))
+}
+
 func newID() RecordID {
 	u4 := uuid.NewV4()
 
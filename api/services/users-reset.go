@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"gopkg.in/redis.v3"
+)
+
+const resetTokenKeyFormat = "reset.%s"
+const resetMailKeyFormat = "resetmail.%s"
+
+// resetMailInterval is how long an address waits between reset mails.
+const resetMailInterval = time.Minute
+
+// RequestPasswordReset mails a link to reset the password of the user with
+// the email. Nothing tells whether there is such a user, so that the request
+// cannot be used to find out; requests for the same address, known or not,
+// return ErrRateLimited within resetMailInterval.
+func (users *Users) RequestPasswordReset(email string) error {
+	mailKey := fmt.Sprintf(resetMailKeyFormat, normalizeEmail(email))
+	first, err := users.redis.SetNX(mailKey, "1", resetMailInterval).Result()
+	if err != nil {
+		return fmt.Errorf("redis.SetNX(%v, 1, %v) failed: %v", mailKey, resetMailInterval, err)
+	} else if !first {
+		return ErrRateLimited
+	}
+
+	user, err := users.getByEmail(email)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	token, err := tokenGenerate(user.ID, DefaultTokenOptions.SecretSize)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf(resetTokenKeyFormat, token)
+	lifetime := time.Duration(users.config.PasswordResetLifetime)
+	err = users.redis.Set(key, string(user.ID), lifetime).Err()
+	if err != nil {
+		return fmt.Errorf("redis.Set(%v, %v, %v) failed: %v", key, user.ID, lifetime, err)
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", users.config.PublicURL, url.QueryEscape(string(token)))
+	return users.mailer.Send(Message{
+		To:      user.Email,
+		Subject: "Reset your huecontrol password",
+		Body: fmt.Sprintf("Someone, hopefully you, asked to reset the password of your huecontrol account.\n\n"+
+			"To choose a new password, follow this link within %v:\n\n%s\n\n"+
+			"If you did not ask for it, you can ignore this mail.\n", lifetime, link),
+	})
+}
+
+// ResetPassword sets the password of the user the reset token was sent to,
+// and logs them out everywhere. The token works once; ErrNotFound is returned
+// if it is unknown, used or expired.
+func (users *Users) ResetPassword(token Token, password string) error {
+	key := fmt.Sprintf(resetTokenKeyFormat, token)
+	userID, err := users.redis.Get(key).Result()
+	if err == redis.Nil {
+		return ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("unable to get key %v: %v", key, err)
+	}
+
+	// only the request that deletes the token may use it
+	deleted, err := users.redis.Del(key).Result()
+	if err != nil {
+		return fmt.Errorf("unable to delete key %v: %v", key, err)
+	} else if deleted == 0 {
+		return ErrNotFound
+	}
+
+	if err := users.setPassword(RecordID(userID), password); err != nil {
+		return err
+	}
+	return users.tokenDeleteAll(RecordID(userID))
+}
+
+func (users *Users) setPassword(userID RecordID, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	res, err := users.db.Exec("UPDATE users SET password=$2 WHERE id=$1", userID, hashedPassword)
+	if err != nil {
+		return fmt.Errorf("unable to set password of user %v: %v", userID, err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to set password of user %v: %v", userID, err)
+	} else if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -15,12 +17,46 @@ import (
 
 const tokenKeyFormat = "token.%s"
 const tokenListKeyFormat = "tokenlist.%s"
+const refreshTokenKeyFormat = "refresh.%s"
+
+// refreshTokenUsedKeyFormat marks a refresh token that was exchanged, holding
+// its data so that a reuse can revoke its family.
+const refreshTokenUsedKeyFormat = "refreshused.%s"
+
+// tokenTouchInterval is how stale the last use of a token may get before it
+// is written back, so that authenticating is usually a single read.
+const tokenTouchInterval = time.Minute
 
 type Token string
 
+// TokenOptions are the settings of a new token. Device is a label for the
+// client, such as its user agent, and IP its address when it logged in.
+// Family groups the tokens descending from the same login through refreshes.
 type TokenOptions struct {
 	Duration   time.Duration
 	SecretSize int
+	Device     string
+	IP         string
+	Access     Access
+	Family     string
+	CreatedAt  time.Time
+}
+
+// tokenData is what is stored behind a token.
+type tokenData struct {
+	User       User      `json:"user"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	Device     string    `json:"device,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	Family     string    `json:"family,omitempty"`
+	Access
+}
+
+// storedToken is a token along with its data.
+type storedToken struct {
+	Token Token
+	tokenData
 }
 
 var DefaultTokenOptions = TokenOptions{Duration: 0, SecretSize: 32}
@@ -30,11 +66,28 @@ func (users *Users) tokenCreate(user User) (Token, error) {
 }
 
 func (users *Users) tokenCreateWithOptions(user User, options TokenOptions) (Token, error) {
+	return users.tokenCreateAt(tokenKeyFormat, user, options)
+}
+
+func (users *Users) tokenCreateAt(keyFormat string, user User, options TokenOptions) (Token, error) {
 	mergo.Merge(&options, DefaultTokenOptions)
 
-	userJson, err := json.Marshal(user)
+	now := time.Now().UTC()
+	if options.CreatedAt.IsZero() {
+		options.CreatedAt = now
+	}
+	data := tokenData{
+		User:       user,
+		CreatedAt:  options.CreatedAt,
+		LastUsedAt: now,
+		Device:     options.Device,
+		IP:         options.IP,
+		Family:     options.Family,
+		Access:     options.Access,
+	}
+	dataJson, err := json.Marshal(data)
 	if err != nil {
-		return "", fmt.Errorf("unable to json-encode user %v: %v", user, err)
+		return "", fmt.Errorf("unable to json-encode token data %v: %v", data, err)
 	}
 
 	token, err := tokenGenerate(user.ID, options.SecretSize)
@@ -42,16 +95,16 @@ func (users *Users) tokenCreateWithOptions(user User, options TokenOptions) (Tok
 		return "", err
 	}
 
-	key := fmt.Sprintf(tokenKeyFormat, token)
-	err = users.redis.Set(key, string(userJson), options.Duration).Err()
+	key := fmt.Sprintf(keyFormat, token)
+	err = users.redis.Set(key, string(dataJson), options.Duration).Err()
 	if err != nil {
-		return "", fmt.Errorf("redis.Set(%v, %s, %v) failed: %v", key, userJson, options.Duration, err)
+		return "", fmt.Errorf("redis.Set(%v, %s, %v) failed: %v", key, dataJson, options.Duration, err)
 	}
 
 	tokenListKey := fmt.Sprintf(tokenListKeyFormat, user.ID)
 	err = users.redis.SAdd(tokenListKey, key).Err()
 	if err != nil {
-		return "", fmt.Errorf("redis.Sadd(%v, %s) failed: %v", tokenListKey, key)
+		return "", fmt.Errorf("redis.Sadd(%v, %s) failed: %v", tokenListKey, key, err)
 	}
 
 	return token, nil
@@ -77,21 +130,83 @@ func tokenGenerate(userID RecordID, size int) (Token, error) {
 }
 
 func (users *Users) tokenGetUser(token Token) (User, error) {
+	data, err := users.tokenGet(token)
+	return data.User, err
+}
+
+func (users *Users) tokenGet(token Token) (tokenData, error) {
 	key := fmt.Sprintf(tokenKeyFormat, token)
-	userJson, err := users.redis.Get(key).Result()
+	dataJson, err := users.redis.Get(key).Result()
 	if err == redis.Nil {
-		return User{}, ErrNotFound
+		return tokenData{}, ErrNotFound
 	} else if err != nil {
-		return User{}, fmt.Errorf("unable to get key %v: %v", token, err)
+		return tokenData{}, fmt.Errorf("unable to get key %v: %v", token, err)
+	}
+
+	data, err := parseTokenData(dataJson)
+	if err != nil {
+		return tokenData{}, err
 	}
 
-	user := User{}
-	err = json.Unmarshal([]byte(userJson), &user)
+	if time.Since(data.LastUsedAt) > tokenTouchInterval {
+		if err := users.tokenTouch(key, data); err != nil {
+			return tokenData{}, err
+		}
+	}
+
+	return data, nil
+}
+
+func parseTokenData(dataJson string) (tokenData, error) {
+	data := tokenData{}
+	err := json.Unmarshal([]byte(dataJson), &data)
+	if err != nil {
+		return tokenData{}, fmt.Errorf("unable to unmarshall token data from json %v: %v", dataJson, err)
+	}
+
+	// tokens created before metadata was stored hold just the user
+	if data.User.ID == "" {
+		if err := json.Unmarshal([]byte(dataJson), &data.User); err != nil {
+			return tokenData{}, fmt.Errorf("unable to unmarshall user from json %v: %v", dataJson, err)
+		}
+	}
+	return data, nil
+}
+
+// tokenTouch records that the token was just used, keeping its expiration.
+func (users *Users) tokenTouch(key string, data tokenData) error {
+	ttl, err := users.redis.PTTL(key).Result()
+	if err != nil {
+		return fmt.Errorf("unable to get ttl of key %v: %v", key, err)
+	} else if ttl == -2*time.Millisecond {
+		// expired since it was read
+		return nil
+	}
+
+	data.LastUsedAt = time.Now().UTC()
+	return users.tokenRewrite(key, data, ttl)
+}
+
+// tokenRewrite replaces the data of a token with the given expiration, or none
+// if it is negative. A token deleted since it was read, e.g. by a logout, is
+// left deleted rather than brought back.
+func (users *Users) tokenRewrite(key string, data tokenData, ttl time.Duration) error {
+	dataJson, err := json.Marshal(data)
 	if err != nil {
-		return User{}, fmt.Errorf("unable to unmarshall user from json %v: %v", userJson, err)
+		return fmt.Errorf("unable to json-encode token data %v: %v", data, err)
 	}
 
-	return user, nil
+	var cmd *redis.BoolCmd
+	if ttl > 0 {
+		cmd = users.redis.SetXX(key, string(dataJson), ttl)
+	} else {
+		cmd = redis.NewBoolCmd("SET", key, string(dataJson), "XX")
+		users.redis.Process(cmd)
+	}
+	if err := cmd.Err(); err != nil {
+		return fmt.Errorf("redis.SetXX(%v, %s, %v) failed: %v", key, dataJson, ttl, err)
+	}
+	return nil
 }
 
 func (users *Users) tokenDelete(userID RecordID, token Token) error {
@@ -117,6 +232,7 @@ func (users *Users) tokenDeleteAll(userID RecordID) error {
 		return fmt.Errorf("unable to get members of set %v: %v", tokenListKey, err)
 	}
 
+	keys = append(keys, tokenListKey)
 	err = users.redis.Del(keys...).Err()
 	if err != nil {
 		return fmt.Errorf("unable to delete keys %v: %v", keys, err)
@@ -124,3 +240,258 @@ func (users *Users) tokenDeleteAll(userID RecordID) error {
 
 	return nil
 }
+
+// tokenList returns the user's access tokens that have not expired.
+func (users *Users) tokenList(userID RecordID) ([]storedToken, error) {
+	entries, err := users.tokenListEntries(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf(tokenKeyFormat, "")
+	tokens := make([]storedToken, 0, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.key, prefix) {
+			token := Token(strings.TrimPrefix(entry.key, prefix))
+			tokens = append(tokens, storedToken{token, entry.data})
+		}
+	}
+	return tokens, nil
+}
+
+type tokenListEntry struct {
+	key  string
+	data tokenData
+}
+
+// tokenListEntries returns the keys of the user's token list that have not
+// expired, access and refresh tokens alike, and removes the expired ones.
+func (users *Users) tokenListEntries(userID RecordID) ([]tokenListEntry, error) {
+	tokenListKey := fmt.Sprintf(tokenListKeyFormat, userID)
+	keys, err := users.redis.SMembers(tokenListKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("unable to get members of set %v: %v", tokenListKey, err)
+	} else if len(keys) == 0 {
+		return []tokenListEntry{}, nil
+	}
+
+	values, err := users.redis.MGet(keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get keys %v: %v", keys, err)
+	}
+
+	entries := make([]tokenListEntry, 0, len(keys))
+	expired := make([]string, 0)
+	for i, key := range keys {
+		dataJson, ok := values[i].(string)
+		if !ok {
+			expired = append(expired, key)
+			continue
+		}
+		data, err := parseTokenData(dataJson)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, tokenListEntry{key, data})
+	}
+
+	if len(expired) > 0 {
+		err = users.redis.SRem(tokenListKey, expired...).Err()
+		if err != nil {
+			return nil, fmt.Errorf("unable to remove entries %v from set %v: %v", expired, tokenListKey, err)
+		}
+	}
+
+	return entries, nil
+}
+
+// tokenSession is a login: the tokens of a family, refresh tokens included, or
+// else a token without one. Its data is that of its most recently used key.
+type tokenSession struct {
+	ID     string
+	Family string
+	Tokens []Token
+	tokenData
+}
+
+func (session tokenSession) has(token Token) bool {
+	for _, t := range session.Tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenSessions returns the user's logins that have not expired. A login stays
+// listed as long as its refresh token lives, after its access tokens expired.
+func (users *Users) tokenSessions(userID RecordID) ([]tokenSession, error) {
+	entries, err := users.tokenListEntries(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf(tokenKeyFormat, "")
+	sessions := make([]tokenSession, 0, len(entries))
+	byFamily := make(map[string]int)
+	for _, entry := range entries {
+		var token Token
+		if strings.HasPrefix(entry.key, prefix) {
+			token = Token(strings.TrimPrefix(entry.key, prefix))
+		}
+
+		if entry.data.Family == "" {
+			if token != "" {
+				sessions = append(sessions, tokenSession{ID: tokenID(token), Tokens: []Token{token}, tokenData: entry.data})
+			}
+			continue
+		}
+
+		i, ok := byFamily[entry.data.Family]
+		if !ok {
+			i = len(sessions)
+			byFamily[entry.data.Family] = i
+			sessions = append(sessions, tokenSession{ID: tokenID(Token(entry.data.Family)), Family: entry.data.Family, tokenData: entry.data})
+		} else if entry.data.LastUsedAt.After(sessions[i].LastUsedAt) {
+			sessions[i].tokenData = entry.data
+		}
+		if token != "" {
+			sessions[i].Tokens = append(sessions[i].Tokens, token)
+		}
+	}
+	return sessions, nil
+}
+
+// tokenSessionDelete deletes the tokens of the session, so that no refresh
+// token can bring it back.
+func (users *Users) tokenSessionDelete(userID RecordID, session tokenSession) error {
+	if session.Family != "" {
+		return users.tokenFamilyDelete(userID, session.Family)
+	}
+	for _, token := range session.Tokens {
+		if err := users.tokenDelete(userID, token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tokenFamilyDelete deletes the access and refresh tokens of the family.
+func (users *Users) tokenFamilyDelete(userID RecordID, family string) error {
+	if family == "" {
+		return nil
+	}
+
+	entries, err := users.tokenListEntries(userID)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0)
+	for _, entry := range entries {
+		if entry.data.Family == family {
+			keys = append(keys, entry.key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err = users.redis.Del(keys...).Err()
+	if err != nil {
+		return fmt.Errorf("unable to delete keys %v: %v", keys, err)
+	}
+	tokenListKey := fmt.Sprintf(tokenListKeyFormat, userID)
+	err = users.redis.SRem(tokenListKey, keys...).Err()
+	if err != nil {
+		return fmt.Errorf("unable to remove entries %v from set %v: %v", keys, tokenListKey, err)
+	}
+	return nil
+}
+
+// tokenCreatePair creates a short-lived access token and the refresh token to
+// get its successor, in the family of options.
+func (users *Users) tokenCreatePair(user User, options TokenOptions) (TokenPair, error) {
+	accessLifetime := time.Duration(users.config.AccessTokenLifetime)
+	options.Duration = accessLifetime
+	token, err := users.tokenCreateAt(tokenKeyFormat, user, options)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	options.Duration = time.Duration(users.config.RefreshTokenLifetime)
+	refreshToken, err := users.tokenCreateAt(refreshTokenKeyFormat, user, options)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{Token: token, RefreshToken: refreshToken, ExpiresIn: int64(accessLifetime / time.Second)}, nil
+}
+
+// tokenRefresh exchanges the refresh token for a new pair, replacing the
+// tokens of its family. A refresh token is marked as used before anything
+// else, so that it works only once even when presented twice at the same time.
+func (users *Users) tokenRefresh(refreshToken Token, options TokenOptions) (User, TokenPair, error) {
+	key := fmt.Sprintf(refreshTokenKeyFormat, refreshToken)
+	usedKey := fmt.Sprintf(refreshTokenUsedKeyFormat, refreshToken)
+
+	dataJson, err := users.redis.Get(key).Result()
+	if err == redis.Nil {
+		dataJson, err = users.redis.Get(usedKey).Result()
+		if err == redis.Nil {
+			return User{}, TokenPair{}, ErrNotFound
+		} else if err != nil {
+			return User{}, TokenPair{}, fmt.Errorf("unable to get key %v: %v", usedKey, err)
+		}
+		return User{}, TokenPair{}, users.tokenRefreshReused(dataJson)
+	} else if err != nil {
+		return User{}, TokenPair{}, fmt.Errorf("unable to get key %v: %v", key, err)
+	}
+
+	lifetime := time.Duration(users.config.RefreshTokenLifetime)
+	first, err := users.redis.SetNX(usedKey, dataJson, lifetime).Result()
+	if err != nil {
+		return User{}, TokenPair{}, fmt.Errorf("redis.SetNX(%v, %s, %v) failed: %v", usedKey, dataJson, lifetime, err)
+	} else if !first {
+		return User{}, TokenPair{}, users.tokenRefreshReused(dataJson)
+	}
+
+	data, err := parseTokenData(dataJson)
+	if err != nil {
+		return User{}, TokenPair{}, err
+	}
+	if err := users.tokenFamilyDelete(data.User.ID, data.Family); err != nil {
+		return User{}, TokenPair{}, err
+	}
+
+	pair, err := users.tokenCreatePair(data.User, TokenOptions{
+		Device:    data.Device,
+		IP:        options.IP,
+		Access:    data.Access,
+		Family:    data.Family,
+		CreatedAt: data.CreatedAt,
+	})
+	if err != nil {
+		return User{}, TokenPair{}, err
+	}
+	return data.User, pair, nil
+}
+
+// tokenRefreshReused revokes the family of a refresh token used twice: either
+// it or its successor is in the wrong hands.
+func (users *Users) tokenRefreshReused(dataJson string) error {
+	data, err := parseTokenData(dataJson)
+	if err != nil {
+		return err
+	}
+	if err := users.tokenFamilyDelete(data.User.ID, data.Family); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// tokenID identifies a token in listings without revealing it.
+func tokenID(token Token) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:8])
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -155,3 +157,305 @@ func TestTokensDeleteAll(t *testing.T) {
 	}
 
 }
+
+func TestTokensListPrunesExpired(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	token, err := svc.Users.tokenCreate(user)
+	assert.Nil(t, err)
+	duration := time.Millisecond * 50
+	_, err = svc.Users.tokenCreateWithOptions(user, TokenOptions{Duration: duration})
+	assert.Nil(t, err)
+
+	tokens, err := svc.Users.tokenList(user.ID)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 2)
+
+	time.Sleep(duration + 1)
+	tokens, err = svc.Users.tokenList(user.ID)
+	assert.Nil(t, err)
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, token, tokens[0].Token)
+	}
+
+	tokenListKey := fmt.Sprintf(tokenListKeyFormat, user.ID)
+	count, err := svc.redis.SCard(tokenListKey).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count, "expired token should be removed from the list")
+}
+
+func TestTokensListAndRevoke(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	other := mockUser()
+	tokens := make([]Token, 3)
+	for i := range tokens {
+		token, err := svc.Users.tokenCreate(user)
+		assert.Nil(t, err)
+		tokens[i] = token
+	}
+	otherToken, err := svc.Users.tokenCreate(other)
+	assert.Nil(t, err)
+
+	infos, err := svc.Users.ListAccessTokens(user.ID, tokens[0])
+	assert.Nil(t, err)
+	assert.Len(t, infos, 3)
+	currentCount := 0
+	for _, info := range infos {
+		if info.Current {
+			currentCount++
+			assert.Equal(t, tokenID(tokens[0]), info.ID)
+		}
+	}
+	assert.Equal(t, 1, currentCount)
+
+	assert.Equal(t, ErrNotFound, svc.Users.RevokeAccessToken(user.ID, tokenID(otherToken)))
+	assert.Nil(t, svc.Users.RevokeAccessToken(user.ID, tokenID(tokens[1])))
+	_, _, err = svc.Users.AuthenticateWithToken(string(tokens[1]))
+	assert.Equal(t, ErrNotFound, err)
+	_, _, err = svc.Users.AuthenticateWithToken(string(otherToken))
+	assert.Nil(t, err)
+
+	assert.Nil(t, svc.Users.LogoutEverywhere(user.ID))
+	infos, err = svc.Users.ListAccessTokens(user.ID, tokens[0])
+	assert.Nil(t, err)
+	assert.Empty(t, infos)
+	_, _, err = svc.Users.AuthenticateWithToken(string(otherToken))
+	assert.Nil(t, err)
+}
+
+func TestTokensMetadata(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	before := time.Now().UTC()
+	tokens, err := svc.Users.CreateAccessToken(user, TokenOptions{Device: "kitchen tablet", IP: "192.0.2.7"})
+	assert.Nil(t, err)
+
+	infos, err := svc.Users.ListAccessTokens(user.ID, tokens.Token)
+	assert.Nil(t, err)
+	if assert.Len(t, infos, 1) {
+		info := infos[0]
+		assert.Equal(t, "kitchen tablet", info.Device)
+		assert.Equal(t, "192.0.2.7", info.IP)
+		assert.False(t, info.CreatedAt.Before(before))
+		assert.Equal(t, info.CreatedAt, info.LastUsedAt)
+	}
+}
+
+func TestTokensLastUse(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	token, err := svc.Users.tokenCreateWithOptions(user, TokenOptions{Duration: time.Hour})
+	assert.Nil(t, err)
+	key := fmt.Sprintf(tokenKeyFormat, token)
+
+	// a recent use is not written back
+	_, _, err = svc.Users.AuthenticateWithToken(string(token))
+	assert.Nil(t, err)
+	tokens, err := svc.Users.tokenList(user.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, tokens[0].CreatedAt, tokens[0].LastUsedAt)
+
+	// a stale one is, keeping the expiration
+	stale := tokens[0].tokenData
+	stale.LastUsedAt = stale.LastUsedAt.Add(-2 * tokenTouchInterval)
+	staleJson, _ := json.Marshal(stale)
+	assert.Nil(t, svc.redis.Set(key, string(staleJson), time.Hour).Err())
+
+	_, _, err = svc.Users.AuthenticateWithToken(string(token))
+	assert.Nil(t, err)
+	tokens, err = svc.Users.tokenList(user.ID)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), tokens[0].LastUsedAt, time.Second)
+	ttl, err := svc.redis.PTTL(key).Result()
+	assert.Nil(t, err)
+	assert.True(t, ttl > 59*time.Minute, "expiration should be kept, got %v", ttl)
+}
+
+func TestTokensTouchAfterRevoke(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	token, err := svc.Users.tokenCreateWithOptions(user, TokenOptions{Duration: time.Hour})
+	assert.Nil(t, err)
+	key := fmt.Sprintf(tokenKeyFormat, token)
+
+	data, err := svc.Users.tokenGet(token)
+	assert.Nil(t, err)
+	ttl, err := svc.redis.PTTL(key).Result()
+	assert.Nil(t, err)
+	assert.Nil(t, svc.Users.tokenDelete(user.ID, token))
+
+	// deleted after the token was read, and after its expiration was.
+	assert.Nil(t, svc.Users.tokenTouch(key, data))
+	assert.Nil(t, svc.Users.tokenRewrite(key, data, ttl))
+	assert.Nil(t, svc.Users.tokenRewrite(key, data, -1))
+	exists, err := svc.redis.Exists(key).Result()
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	_, err = svc.Users.tokenGet(token)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestTokensWithoutMetadata(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	userJson, _ := json.Marshal(user)
+	token := Token("legacy")
+	assert.Nil(t, svc.redis.Set(fmt.Sprintf(tokenKeyFormat, token), string(userJson), 0).Err())
+
+	actual, _, err := svc.Users.AuthenticateWithToken(string(token))
+	assert.Nil(t, err)
+	assert.Equal(t, user, actual)
+}
+
+func TestTokensScoped(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	bridge := newID()
+	access := Access{Scopes: []Scope{ScopeLightsWrite}, Bridges: []RecordID{bridge}, Groups: []BridgeGroup{{bridge, "1"}}}
+	tokens, err := svc.Users.CreateAccessToken(user, TokenOptions{Access: access})
+	assert.Nil(t, err)
+
+	_, actual, err := svc.Users.AuthenticateWithToken(string(tokens.Token))
+	assert.Nil(t, err)
+	assert.Equal(t, access, actual)
+
+	infos, err := svc.Users.ListAccessTokens(user.ID, tokens.Token)
+	assert.Nil(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, access, infos[0].Access)
+	}
+
+	_, err = svc.Users.CreateAccessToken(user, TokenOptions{Access: Access{Scopes: []Scope{"lights:delete"}}})
+	assert.Equal(t, InvalidAccessError{"lights:delete"}, err)
+}
+
+func TestTokensLifetimes(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	tokens, err := svc.Users.CreateAccessToken(user, TokenOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(time.Duration(svc.Users.config.AccessTokenLifetime)/time.Second), tokens.ExpiresIn)
+
+	ttl, err := svc.redis.PTTL(fmt.Sprintf(tokenKeyFormat, tokens.Token)).Result()
+	assert.Nil(t, err)
+	assert.InDelta(t, float64(svc.Users.config.AccessTokenLifetime), float64(ttl), float64(time.Second))
+
+	ttl, err = svc.redis.PTTL(fmt.Sprintf(refreshTokenKeyFormat, tokens.RefreshToken)).Result()
+	assert.Nil(t, err)
+	assert.InDelta(t, float64(svc.Users.config.RefreshTokenLifetime), float64(ttl), float64(time.Second))
+
+	// a refresh token is not an access token
+	_, _, err = svc.Users.AuthenticateWithToken(string(tokens.RefreshToken))
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestTokensRefresh(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	access := Access{Scopes: []Scope{ScopeLightsRead}}
+	first, err := svc.Users.CreateAccessToken(user, TokenOptions{Device: "tablet", IP: "192.0.2.7", Access: access})
+	assert.Nil(t, err)
+
+	actualUser, second, err := svc.Users.RefreshAccessToken(first.RefreshToken, TokenOptions{IP: "192.0.2.8"})
+	assert.Nil(t, err)
+	assert.Equal(t, user, actualUser)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	// the new token replaces the old one, with the same device and access
+	_, _, err = svc.Users.AuthenticateWithToken(string(first.Token))
+	assert.Equal(t, ErrNotFound, err)
+	_, actualAccess, err := svc.Users.AuthenticateWithToken(string(second.Token))
+	assert.Nil(t, err)
+	assert.Equal(t, access, actualAccess)
+	infos, err := svc.Users.ListAccessTokens(user.ID, second.Token)
+	assert.Nil(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, "tablet", infos[0].Device)
+		assert.Equal(t, "192.0.2.8", infos[0].IP)
+	}
+
+	_, _, err = svc.Users.RefreshAccessToken("unknown", TokenOptions{})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestTokensRefreshReuse(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	stolen, err := svc.Users.CreateAccessToken(user, TokenOptions{})
+	assert.Nil(t, err)
+	other, err := svc.Users.CreateAccessToken(user, TokenOptions{})
+	assert.Nil(t, err)
+
+	_, legit, err := svc.Users.RefreshAccessToken(stolen.RefreshToken, TokenOptions{})
+	assert.Nil(t, err)
+
+	// using the first refresh token again revokes the whole family, but not other logins
+	_, _, err = svc.Users.RefreshAccessToken(stolen.RefreshToken, TokenOptions{})
+	assert.Equal(t, ErrRefreshTokenReused, err)
+	_, _, err = svc.Users.AuthenticateWithToken(string(legit.Token))
+	assert.Equal(t, ErrNotFound, err)
+	_, _, err = svc.Users.RefreshAccessToken(legit.RefreshToken, TokenOptions{})
+	assert.Equal(t, ErrNotFound, err)
+
+	_, _, err = svc.Users.AuthenticateWithToken(string(other.Token))
+	assert.Nil(t, err)
+}
+
+func TestTokensRevokeFamily(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	tokens, err := svc.Users.CreateAccessToken(user, TokenOptions{})
+	assert.Nil(t, err)
+
+	assert.Nil(t, svc.Users.Logout(user.ID, tokens.Token))
+	_, _, err = svc.Users.RefreshAccessToken(tokens.RefreshToken, TokenOptions{})
+	assert.Equal(t, ErrNotFound, err)
+
+	tokens, err = svc.Users.CreateAccessToken(user, TokenOptions{})
+	assert.Nil(t, err)
+	infos, err := svc.Users.ListAccessTokens(user.ID, tokens.Token)
+	assert.Nil(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Nil(t, svc.Users.RevokeAccessToken(user.ID, infos[0].ID))
+	}
+	_, _, err = svc.Users.RefreshAccessToken(tokens.RefreshToken, TokenOptions{})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestTokensSessions(t *testing.T) {
+	svc := setupTokensTest(t)
+	user := mockUser()
+	first, err := svc.Users.CreateAccessToken(user, TokenOptions{Device: "phone"})
+	assert.Nil(t, err)
+	_, second, err := svc.Users.RefreshAccessToken(first.RefreshToken, TokenOptions{})
+	assert.Nil(t, err)
+
+	// a refreshed login is listed once, as current for its new token
+	infos, err := svc.Users.ListAccessTokens(user.ID, second.Token)
+	assert.Nil(t, err)
+	if !assert.Len(t, infos, 1) {
+		return
+	}
+	assert.True(t, infos[0].Current)
+	assert.Equal(t, "phone", infos[0].Device)
+	id := infos[0].ID
+
+	// and still listed once its access token expired, until revoked
+	assert.Nil(t, svc.redis.Del(fmt.Sprintf(tokenKeyFormat, second.Token)).Err())
+	infos, err = svc.Users.ListAccessTokens(user.ID, "")
+	assert.Nil(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, id, infos[0].ID)
+		assert.False(t, infos[0].Current)
+	}
+
+	assert.Nil(t, svc.Users.RevokeAccessToken(user.ID, id))
+	_, _, err = svc.Users.RefreshAccessToken(second.RefreshToken, TokenOptions{})
+	assert.Equal(t, ErrNotFound, err)
+	infos, err = svc.Users.ListAccessTokens(user.ID, "")
+	assert.Nil(t, err)
+	assert.Empty(t, infos)
+
+	assert.Equal(t, ErrNotFound, svc.Users.Logout(user.ID, second.Token))
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const verificationMailKeyFormat = "verificationmail.%s"
+
+// verificationMailInterval is how long a user waits between verification mails.
+const verificationMailInterval = time.Minute
+
+var (
+	ErrAlreadyVerified = fmt.Errorf("already_verified")
+	ErrRateLimited     = fmt.Errorf("rate_limited")
+)
+
+// SendVerification mails the user a link to verify their email address.
+func (users *Users) SendVerification(user User) error {
+	if user.Verified {
+		return ErrAlreadyVerified
+	}
+
+	key := fmt.Sprintf(verificationMailKeyFormat, user.ID)
+	first, err := users.redis.SetNX(key, "1", verificationMailInterval).Result()
+	if err != nil {
+		return fmt.Errorf("redis.SetNX(%v, 1, %v) failed: %v", key, verificationMailInterval, err)
+	} else if !first {
+		return ErrRateLimited
+	}
+
+	lifetime := time.Duration(users.config.VerificationLifetime)
+	token := users.verificationToken(user, time.Now().Add(lifetime))
+	link := fmt.Sprintf("%s/verify-email?token=%s", users.config.PublicURL, url.QueryEscape(token))
+	return users.mailer.Send(Message{
+		To:      user.Email,
+		Subject: "Verify your huecontrol email address",
+		Body: fmt.Sprintf("Welcome to huecontrol!\n\n"+
+			"To verify your email address, follow this link within %v:\n\n%s\n\n"+
+			"If you did not sign up, you can ignore this mail.\n", lifetime, link),
+	})
+}
+
+// Verify marks the address of the link's user as verified. ErrNotFound is
+// returned if the token is not one we signed, has expired, or was sent to an
+// address the user no longer has.
+func (users *Users) Verify(token string) (User, error) {
+	userID, email, err := users.parseVerificationToken(token, time.Now())
+	if err != nil {
+		return User{}, err
+	}
+
+	res, err := users.db.Exec(
+		"UPDATE users SET verified_at=COALESCE(verified_at, $3) WHERE id=$1 AND email=$2",
+		userID, email, time.Now().UTC())
+	if err != nil {
+		return User{}, fmt.Errorf("unable to verify user %v: %v", userID, err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return User{}, fmt.Errorf("unable to verify user %v: %v", userID, err)
+	} else if count == 0 {
+		return User{}, ErrNotFound
+	}
+	return users.GetByID(userID)
+}
+
+func (users *Users) IsVerified(userID RecordID) (bool, error) {
+	var verified bool
+	err := users.db.
+		QueryRow("SELECT verified_at IS NOT NULL FROM users WHERE id=$1", userID).
+		Scan(&verified)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	} else if err != nil {
+		return false, fmt.Errorf("Error fetching user %v: %v", userID, err)
+	}
+	return verified, nil
+}
+
+// AllowsUnverified tells whether accounts whose address is not verified may
+// use the scope.
+func (users *Users) AllowsUnverified(scope Scope) bool {
+	scopes := users.config.UnverifiedScopes
+	if scopes == nil || len(*scopes) == 0 {
+		// unlike that of a token, an empty list of scopes allows nothing.
+		return false
+	}
+	return Access{Scopes: *scopes}.Allows(scope)
+}
+
+// verificationToken signs the user's ID and address along with an expiration,
+// so that verifying needs nothing stored.
+func (users *Users) verificationToken(user User, expires time.Time) string {
+	payload := fmt.Sprintf("%s:%s:%d", user.ID, user.Email, expires.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(users.verificationSignature(payload))
+}
+
+func (users *Users) parseVerificationToken(token string, now time.Time) (RecordID, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", "", ErrNotFound
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", "", ErrNotFound
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, users.verificationSignature(string(payload))) {
+		return "", "", ErrNotFound
+	}
+
+	// the address may itself contain colons, unlike the ID and the expiration
+	// on either side of it.
+	first, last := strings.Index(string(payload), ":"), strings.LastIndex(string(payload), ":")
+	if first < 0 || first == last {
+		return "", "", ErrNotFound
+	}
+	expires, err := strconv.ParseInt(string(payload[last+1:]), 10, 64)
+	if err != nil || now.Unix() > expires {
+		return "", "", ErrNotFound
+	}
+	return RecordID(payload[:first]), string(payload[first+1 : last]), nil
+}
+
+func (users *Users) verificationSignature(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(users.config.VerificationSecret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
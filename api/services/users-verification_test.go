@@ -0,0 +1,119 @@
+package services
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var verificationTestSvc *Services
+
+func setupVerificationTest(t *testing.T) (*Services, *recordingMailer) {
+	testSuiteSetup(&verificationTestSvc)
+	flushRedis(verificationTestSvc)
+
+	mailer := &recordingMailer{}
+	verificationTestSvc.Users.mailer = mailer
+	return verificationTestSvc, mailer
+}
+
+func TestVerificationToken(t *testing.T) {
+	users := &Users{config: Config{VerificationSecret: "secret"}}
+	user := mockUser()
+	now := time.Now()
+	token := users.verificationToken(user, now.Add(time.Hour))
+
+	userID, email, err := users.parseVerificationToken(token, now)
+	assert.Nil(t, err)
+	assert.Equal(t, user.ID, userID)
+	assert.Equal(t, user.Email, email)
+
+	_, _, err = users.parseVerificationToken(token, now.Add(2*time.Hour))
+	assert.Equal(t, ErrNotFound, err, "expired")
+
+	other := &Users{config: Config{VerificationSecret: "other secret"}}
+	_, _, err = other.parseVerificationToken(token, now)
+	assert.Equal(t, ErrNotFound, err, "signed with another secret")
+
+	forged := users.verificationToken(mockUser(), now.Add(time.Hour))
+	_, _, err = users.parseVerificationToken(forged[:len(forged)-43]+token[len(token)-43:], now)
+	assert.Equal(t, ErrNotFound, err, "signature of another token")
+
+	user.Email = `"a:b"@example.com`
+	token = users.verificationToken(user, now.Add(time.Hour))
+	userID, email, err = users.parseVerificationToken(token, now)
+	assert.Nil(t, err, "colon in the address")
+	assert.Equal(t, user.ID, userID)
+	assert.Equal(t, user.Email, email)
+
+	for _, invalid := range []string{"", "abc", "a.b.c", "!!!.???"} {
+		_, _, err = users.parseVerificationToken(invalid, now)
+		assert.Equal(t, ErrNotFound, err, invalid)
+	}
+}
+
+func TestVerificationAllowsUnverified(t *testing.T) {
+	users := &Users{config: Config{UnverifiedScopes: &[]Scope{ScopeLightsWrite, ScopeAccount}}}
+	assert.True(t, users.AllowsUnverified(ScopeLightsRead))
+	assert.True(t, users.AllowsUnverified(ScopeAccount))
+	assert.False(t, users.AllowsUnverified(ScopeBridgesAdmin))
+
+	users.config.UnverifiedScopes = &[]Scope{}
+	for _, scope := range Scopes {
+		assert.False(t, users.AllowsUnverified(scope), scope)
+	}
+}
+
+func TestVerificationMail(t *testing.T) {
+	svc, mailer := setupVerificationTest(t)
+	user := mockUser()
+
+	assert.Nil(t, svc.Users.SendVerification(user))
+	assert.Equal(t, ErrRateLimited, svc.Users.SendVerification(user))
+	if assert.Len(t, mailer.sent, 1) {
+		assert.Equal(t, user.Email, mailer.sent[0].To)
+		token := verificationTokenOf(t, mailer.sent[0])
+		userID, _, err := svc.Users.parseVerificationToken(token, time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, userID)
+	}
+
+	user.Verified = true
+	assert.Equal(t, ErrAlreadyVerified, svc.Users.SendVerification(user))
+}
+
+func TestUsersVerify(t *testing.T) {
+	svc, mailer := setupVerificationTest(t)
+	user, err := svc.Users.Create(randEmail(), randWord(16))
+	assert.Nil(t, err)
+	assert.False(t, user.Verified)
+
+	assert.Nil(t, svc.Users.SendVerification(user))
+	if !assert.Len(t, mailer.sent, 1) {
+		return
+	}
+	token := verificationTokenOf(t, mailer.sent[0])
+
+	verified, err := svc.Users.Verify(token)
+	assert.Nil(t, err)
+	assert.True(t, verified.Verified)
+	isVerified, err := svc.Users.IsVerified(user.ID)
+	assert.Nil(t, err)
+	assert.True(t, isVerified)
+
+	_, err = svc.Users.Verify(svc.Users.verificationToken(User{ID: user.ID, Email: randEmail()}, time.Now().Add(time.Hour)))
+	assert.Equal(t, ErrNotFound, err, "address the user does not have")
+}
+
+func verificationTokenOf(t *testing.T, msg Message) string {
+	match := regexp.MustCompile(`token=(\S+)`).FindStringSubmatch(msg.Body)
+	if !assert.Len(t, match, 2) {
+		return ""
+	}
+	token, err := url.QueryUnescape(match[1])
+	assert.Nil(t, err)
+	return token
+}
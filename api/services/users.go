@@ -3,7 +3,9 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
@@ -16,6 +18,7 @@ const bcryptCost = 8
 type User struct {
 	ID       RecordID `json:"id"`
 	Email    string   `json:"email"`
+	Verified bool     `json:"verified"`
 	password string
 }
 
@@ -27,10 +30,11 @@ type Users struct {
 	config Config
 	db     *sql.DB
 	redis  *redis.Client
+	mailer Mailer
 }
 
-func newUsers(config Config, db *sql.DB, redisClient *redis.Client) (*Users, error) {
-	return &Users{config, db, redisClient}, nil
+func newUsers(config Config, db *sql.DB, redisClient *redis.Client, mailer Mailer) (*Users, error) {
+	return &Users{config, db, redisClient, mailer}, nil
 }
 
 func (users *Users) Create(email string, password string) (User, error) {
@@ -87,8 +91,8 @@ func normalizeID(id string) string {
 func (users *Users) GetByID(userID RecordID) (User, error) {
 	user := User{ID: userID}
 	err := users.db.
-		QueryRow("SELECT email,password FROM users WHERE id=$1", userID).
-		Scan(&user.Email, &user.password)
+		QueryRow("SELECT email,password,verified_at IS NOT NULL FROM users WHERE id=$1", userID).
+		Scan(&user.Email, &user.password, &user.Verified)
 	if err == sql.ErrNoRows {
 		return User{}, ErrNotFound
 	} else if err != nil {
@@ -98,15 +102,23 @@ func (users *Users) GetByID(userID RecordID) (User, error) {
 	}
 }
 
-func (users *Users) AuthenticateWithPassword(email string, password string) (User, error) {
+func (users *Users) getByEmail(email string) (User, error) {
 	user := User{Email: normalizeEmail(email)}
 	err := users.db.
-		QueryRow("SELECT id,password FROM users WHERE email=$1", user.Email).
-		Scan(&user.ID, &user.password)
+		QueryRow("SELECT id,password,verified_at IS NOT NULL FROM users WHERE email=$1", user.Email).
+		Scan(&user.ID, &user.password, &user.Verified)
 	if err == sql.ErrNoRows {
 		return User{}, ErrNotFound
 	} else if err != nil {
 		return User{}, fmt.Errorf("Error fetching user %v: %v", email, err)
+	}
+	return user, nil
+}
+
+func (users *Users) AuthenticateWithPassword(email string, password string) (User, error) {
+	user, err := users.getByEmail(email)
+	if err != nil {
+		return User{}, err
 	} else if !verifyPassword(password, user) {
 		return User{}, ErrNotFound
 	} else {
@@ -118,10 +130,123 @@ func verifyPassword(password string, user User) bool {
 	return bcrypt.CompareHashAndPassword([]byte(user.password), []byte(password)) == nil
 }
 
-func (users *Users) AuthenticateWithToken(token string) (User, error) {
-	return users.tokenGetUser(Token(token))
+// AuthenticateWithToken returns the user of the token, and what the token
+// allows them to do.
+func (users *Users) AuthenticateWithToken(token string) (User, Access, error) {
+	data, err := users.tokenGet(Token(token))
+	if err != nil {
+		return User{}, Access{}, err
+	}
+	return data.User, data.Access, nil
+}
+
+var ErrRefreshTokenReused = fmt.Errorf("refresh_token_reused")
+
+// TokenPair is an access token, valid for ExpiresIn seconds, and the refresh
+// token to exchange for the next pair.
+type TokenPair struct {
+	Token        Token `json:"token"`
+	RefreshToken Token `json:"refreshToken"`
+	ExpiresIn    int64 `json:"expiresIn"`
+}
+
+// CreateAccessToken logs the user in, starting a new family of tokens. Only the
+// Device, IP and Access of options are used.
+func (users *Users) CreateAccessToken(user User, options TokenOptions) (TokenPair, error) {
+	if err := options.Access.Validate(); err != nil {
+		return TokenPair{}, err
+	}
+	return users.tokenCreatePair(user, TokenOptions{
+		Device: options.Device,
+		IP:     options.IP,
+		Access: options.Access,
+		Family: string(newID()),
+	})
 }
 
-func (users *Users) CreateAccessToken(user User) (Token, error) {
-	return users.tokenCreate(user)
+// RefreshAccessToken exchanges a refresh token for a new pair, keeping what
+// the first token of its family allowed; only the IP of options is used. A
+// refresh token works once: using it again revokes its whole family and
+// returns ErrRefreshTokenReused.
+func (users *Users) RefreshAccessToken(refreshToken Token, options TokenOptions) (User, TokenPair, error) {
+	return users.tokenRefresh(refreshToken, options)
+}
+
+// TokenInfo describes an active login without revealing its tokens.
+type TokenInfo struct {
+	ID         string    `json:"id"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	Device     string    `json:"device,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	Access
+}
+
+// ListAccessTokens returns the user's active logins, most recently used first;
+// current is the token of the request, if any.
+func (users *Users) ListAccessTokens(userID RecordID, current Token) ([]TokenInfo, error) {
+	sessions, err := users.tokenSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]TokenInfo, len(sessions))
+	for i, session := range sessions {
+		infos[i] = TokenInfo{
+			ID:         session.ID,
+			Current:    current != "" && session.has(current),
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			Device:     session.Device,
+			IP:         session.IP,
+			Access:     session.Access,
+		}
+	}
+	sort.Sort(tokenInfosByLastUse(infos))
+	return infos, nil
+}
+
+// RevokeAccessToken revokes the login with the ID shown by ListAccessTokens,
+// refresh token included.
+func (users *Users) RevokeAccessToken(userID RecordID, id string) error {
+	sessions, err := users.tokenSessions(userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID == id {
+			return users.tokenSessionDelete(userID, session)
+		}
+	}
+	return ErrNotFound
+}
+
+// Logout revokes the login of the token, refresh token included.
+func (users *Users) Logout(userID RecordID, token Token) error {
+	sessions, err := users.tokenSessions(userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.has(token) {
+			return users.tokenSessionDelete(userID, session)
+		}
+	}
+	return ErrNotFound
+}
+
+func (users *Users) LogoutEverywhere(userID RecordID) error {
+	return users.tokenDeleteAll(userID)
+}
+
+type tokenInfosByLastUse []TokenInfo
+
+func (infos tokenInfosByLastUse) Len() int      { return len(infos) }
+func (infos tokenInfosByLastUse) Swap(i, j int) { infos[i], infos[j] = infos[j], infos[i] }
+func (infos tokenInfosByLastUse) Less(i, j int) bool {
+	if !infos[i].LastUsedAt.Equal(infos[j].LastUsedAt) {
+		return infos[i].LastUsedAt.After(infos[j].LastUsedAt)
+	}
+	return infos[i].ID < infos[j].ID
 }
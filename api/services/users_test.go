@@ -1,7 +1,9 @@
 package services
 
 import (
+	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 )
 import "github.com/stretchr/testify/assert"
@@ -164,3 +166,54 @@ func TestUsersAuthenticateWithPassword(t *testing.T) {
 	}
 
 }
+
+// recordingMailer keeps the mail sent instead of sending it.
+type recordingMailer struct {
+	sent []Message
+}
+
+func (mailer *recordingMailer) Send(msg Message) error {
+	mailer.sent = append(mailer.sent, msg)
+	return nil
+}
+
+func TestUsersPasswordReset(t *testing.T) {
+	svc := setupUsersTest(t)
+	mailer := &recordingMailer{}
+	defer func(previous Mailer) { svc.Users.mailer = previous }(svc.Users.mailer)
+	svc.Users.mailer = mailer
+
+	email := randEmail()
+	user, err := svc.Users.Create(email, "old-password")
+	assert.Nil(t, err)
+	tokens, err := svc.Users.CreateAccessToken(user, TokenOptions{})
+	assert.Nil(t, err)
+
+	unknown := randEmail()
+	assert.Nil(t, svc.Users.RequestPasswordReset(unknown))
+	assert.Equal(t, ErrRateLimited, svc.Users.RequestPasswordReset(unknown))
+	assert.Empty(t, mailer.sent)
+
+	assert.Nil(t, svc.Users.RequestPasswordReset(strings.ToUpper(email)))
+	assert.Equal(t, ErrRateLimited, svc.Users.RequestPasswordReset(email))
+	if !assert.Len(t, mailer.sent, 1) {
+		return
+	}
+	assert.Equal(t, user.Email, mailer.sent[0].To)
+	match := regexp.MustCompile(`token=(\S+)`).FindStringSubmatch(mailer.sent[0].Body)
+	if !assert.Len(t, match, 2) {
+		return
+	}
+	resetToken, err := url.QueryUnescape(match[1])
+	assert.Nil(t, err)
+
+	assert.Nil(t, svc.Users.ResetPassword(Token(resetToken), "new-password"))
+	_, err = svc.Users.AuthenticateWithPassword(email, "old-password")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = svc.Users.AuthenticateWithPassword(email, "new-password")
+	assert.Nil(t, err)
+	_, _, err = svc.Users.AuthenticateWithToken(string(tokens.Token))
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.Equal(t, ErrNotFound, svc.Users.ResetPassword(Token(resetToken), "another-password"))
+}
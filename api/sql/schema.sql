@@ -1,20 +1,26 @@
 CREATE TABLE users(
   id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
   email VARCHAR(256) UNIQUE NOT NULL CHECK(email ~ '^[a-zA-Z0-9_%+-]+@[a-zA-Z0-9-]+\.[a-zA-Z0-9][a-zA-Z0-9]+$'),
-  password VARCHAR(128) NOT NULL
+  password VARCHAR(128) NOT NULL,
+  verified_at TIMESTAMP WITH TIME ZONE
 );
 
 CREATE TABLE bridges(
   id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
-  user_id uuid REFERENCES users(id) NOT NULL,
-  api_token VARCHAR(256) NOT NULL,
-  device_id VARCHAR(256) NOT NULL
+  user_id uuid REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+  bridge_id VARCHAR(32) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  address VARCHAR(256) NOT NULL,
+  username VARCHAR(64) NOT NULL,
+  remote_token VARCHAR(256),
+  verified_at TIMESTAMP WITH TIME ZONE,
+  UNIQUE(user_id, bridge_id)
 );
 
 CREATE TYPE schedule_status AS ENUM ('enabled', 'disabled');
 CREATE TABLE schedules(
   id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
-  bridge_id uuid REFERENCES bridges(id) NOT NULL,
+  bridge_id uuid REFERENCES bridges(id) ON DELETE CASCADE NOT NULL,
   user_id uuid REFERENCES users(id) NOT NULL,
   name VARCHAR(32) NOT NULL,
   description VARCHAR(64) NOT NULL,
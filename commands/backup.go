@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+var backupCommand = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "Export everything on the bridge to a JSON archive",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		backup, err := client.Backup()
+		if err != nil {
+			return err
+		}
+
+		contents, err := json.MarshalIndent(backup, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to encode backup: %v", err)
+		}
+
+		if len(args) == 0 {
+			_, err = os.Stdout.Write(contents)
+			return err
+		}
+		return ioutil.WriteFile(args[0], contents, 0600)
+	}),
+}
+
+var restoreCommand = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Rebuild the bridge from a JSON archive created by backup",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("restore: expected exactly one backup file")
+		}
+
+		contents, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("Unable to read backup file: %v", err)
+		}
+		var backup hue.Backup
+		if err := json.Unmarshal(contents, &backup); err != nil {
+			return fmt.Errorf("Unable to parse backup file: %v", err)
+		}
+
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		report, err := client.Restore(backup)
+		if err != nil {
+			return err
+		}
+
+		resources := make([]string, 0, len(report.Missing))
+		for resource := range report.Missing {
+			resources = append(resources, resource)
+		}
+		sort.Strings(resources)
+		for _, resource := range resources {
+			fmt.Fprintf(os.Stderr, "not restored: %v %v\n", resource, report.Missing[resource])
+		}
+		return nil
+	}),
+}
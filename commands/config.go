@@ -0,0 +1,353 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+const (
+	envPrefix          = "HUECONTROL_"
+	defaultProfileName = "default"
+	legacyConfigFile   = "./.config.json"
+
+	schemeHTTP  = "http"
+	schemeHTTPS = "https"
+)
+
+// profile holds what is needed to talk to one bridge. Scheme is http or
+// https; profiles saved before it existed use https if they have a BridgeID.
+type profile struct {
+	Hostname        string `json:"hostname"`
+	Username        string `json:"username"`
+	BridgeID        string `json:"bridgeID,omitempty"`
+	CertFingerprint string `json:"certFingerprint,omitempty"`
+	Scheme          string `json:"scheme,omitempty"`
+}
+
+var profileKeys = []string{"hostname", "username", "bridgeid", "certfingerprint", "scheme"}
+
+func (p *profile) field(key string) (*string, error) {
+	switch strings.ToLower(key) {
+	case "hostname":
+		return &p.Hostname, nil
+	case "username":
+		return &p.Username, nil
+	case "bridgeid":
+		return &p.BridgeID, nil
+	case "certfingerprint":
+		return &p.CertFingerprint, nil
+	case "scheme":
+		return &p.Scheme, nil
+	default:
+		return nil, fmt.Errorf("unknown config key %q; expected one of %v", key, strings.Join(profileKeys, ", "))
+	}
+}
+
+// useTLS tells whether the bridge is reached over https, with its certificate
+// pinned.
+func (p profile) useTLS() bool {
+	if p.Scheme != "" {
+		return p.Scheme == schemeHTTPS
+	}
+	return p.BridgeID != ""
+}
+
+func validateScheme(scheme string) error {
+	if scheme != "" && scheme != schemeHTTP && scheme != schemeHTTPS {
+		return fmt.Errorf("invalid scheme %q; expected %v or %v", scheme, schemeHTTP, schemeHTTPS)
+	}
+	return nil
+}
+
+func (p profile) validate() error {
+	if err := validateScheme(p.Scheme); err != nil {
+		return err
+	}
+	if p.useTLS() && p.BridgeID == "" {
+		return fmt.Errorf("the bridge ID is needed to verify the bridge certificate over %v; set bridgeid", schemeHTTPS)
+	}
+	return nil
+}
+
+// applyEnv overrides the profile with HUECONTROL_HOSTNAME, HUECONTROL_USERNAME, etc.
+func (p *profile) applyEnv() {
+	for _, key := range profileKeys {
+		if value := os.Getenv(envPrefix + strings.ToUpper(key)); value != "" {
+			field, _ := p.field(key)
+			*field = value
+		}
+	}
+}
+
+type config struct {
+	Current  string             `json:"current,omitempty"`
+	Profiles map[string]profile `json:"profiles"`
+}
+
+// profileFlag is set by the global --profile flag.
+var profileFlag string
+
+// profileName returns the selected profile: --profile, then
+// HUECONTROL_PROFILE, then the current profile of the config file.
+func (cfg config) profileName() string {
+	if profileFlag != "" {
+		return profileFlag
+	} else if name := os.Getenv(envPrefix + "PROFILE"); name != "" {
+		return name
+	} else if cfg.Current != "" {
+		return cfg.Current
+	}
+	return defaultProfileName
+}
+
+// configPath is $HUECONTROL_CONFIG, or huecontrol/config.json in the XDG config directory.
+func configPath() (string, error) {
+	if path := os.Getenv(envPrefix + "CONFIG"); path != "" {
+		return path, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return "", fmt.Errorf("Unable to locate config directory: neither XDG_CONFIG_HOME nor HOME is set")
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "huecontrol", "config.json"), nil
+}
+
+func loadConfig() (config, error) {
+	cfg := config{Profiles: map[string]profile{}}
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return loadLegacyConfig(cfg)
+	} else if err != nil {
+		return cfg, fmt.Errorf("Unable to read config file: %v", err)
+	}
+
+	if err := json.Unmarshal(contents, &cfg); err != nil {
+		return cfg, fmt.Errorf("Unable to parse config file %v: %v", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]profile{}
+	}
+	return cfg, nil
+}
+
+// loadLegacyConfig imports the ./.config.json of earlier versions as the default profile.
+func loadLegacyConfig(cfg config) (config, error) {
+	contents, err := ioutil.ReadFile(legacyConfigFile)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, fmt.Errorf("Unable to read config file: %v", err)
+	}
+
+	var p profile
+	if err := json.Unmarshal(contents, &p); err != nil {
+		return cfg, fmt.Errorf("Unable to parse config file %v: %v", legacyConfigFile, err)
+	}
+	cfg.Profiles[defaultProfileName] = p
+	return cfg, nil
+}
+
+func saveConfig(cfg config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	contents, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to encode config: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("Unable to create config directory: %v", err)
+	}
+	if err := ioutil.WriteFile(path, contents, 0600); err != nil {
+		return fmt.Errorf("Unable to write config file: %v", err)
+	}
+	return nil
+}
+
+// loadProfile returns the selected profile, with environment overrides applied.
+func loadProfile() (string, profile, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", profile{}, err
+	}
+	name := cfg.profileName()
+	p := cfg.Profiles[name]
+	p.applyEnv()
+	return name, p, nil
+}
+
+// updateProfile changes a profile in the config file, creating it if needed.
+// The first profile created becomes the current one.
+func updateProfile(name string, update func(p *profile)) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	p := cfg.Profiles[name]
+	update(&p)
+	cfg.Profiles[name] = p
+	if cfg.Current == "" {
+		cfg.Current = name
+	}
+	return saveConfig(cfg)
+}
+
+func newClient() (*hue.Client, error) {
+	name, p, err := loadProfile()
+	if err != nil {
+		return nil, err
+	}
+	if p.Hostname == "" || p.Username == "" {
+		return nil, fmt.Errorf("no bridge configured for profile %q; run huecontrol pair", name)
+	} else if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid profile %q: %v", name, err)
+	}
+
+	if !p.useTLS() {
+		return hue.New(p.Hostname, p.Username), nil
+	}
+
+	pin := hue.CertificatePin{
+		BridgeID:    p.BridgeID,
+		Fingerprint: p.CertFingerprint,
+		OnFirstUse: func(fingerprint string) error {
+			return updateProfile(name, func(p *profile) {
+				p.CertFingerprint = fingerprint
+			})
+		},
+	}
+	return hue.NewWithTLS(p.Hostname, p.Username, pin.TLSConfig()), nil
+}
+
+var configCommand = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change the CLI configuration",
+	Long: `Show and change the CLI configuration.
+
+The configuration holds one profile per bridge. The profile is selected with
+--profile, then HUECONTROL_PROFILE, then the current profile. Any setting of
+the profile can be overridden by an environment variable, e.g.
+HUECONTROL_HOSTNAME. Set scheme to http for bridges that do not serve https.
+The file is $XDG_CONFIG_HOME/huecontrol/config.json, unless HUECONTROL_CONFIG
+is set.`,
+}
+
+var configGetCommand = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a setting of the selected profile",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		_, p, err := loadProfile()
+		if err != nil {
+			return err
+		}
+		field, err := p.field(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(*field)
+		return nil
+	}),
+}
+
+var configSetCommand = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting of the selected profile",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 2); err != nil {
+			return err
+		}
+		if _, err := (&profile{}).field(args[0]); err != nil {
+			return err
+		} else if strings.ToLower(args[0]) == "scheme" {
+			if err := validateScheme(args[1]); err != nil {
+				return err
+			}
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		return updateProfile(cfg.profileName(), func(p *profile) {
+			field, _ := p.field(args[0])
+			*field = args[1]
+		})
+	}),
+}
+
+var configListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "List all profiles",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(cfg.Profiles))
+		for name := range cfg.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		selected := cfg.profileName()
+		for _, name := range names {
+			marker := " "
+			if name == selected {
+				marker = "*"
+			}
+			p := cfg.Profiles[name]
+			scheme := schemeHTTP
+			if p.useTLS() {
+				scheme = schemeHTTPS
+			}
+			fmt.Printf("%s %s\thostname=%s username=%s bridgeid=%s scheme=%s\n", marker, name, p.Hostname, p.Username, p.BridgeID, scheme)
+		}
+		return nil
+	}),
+}
+
+var configUseCommand = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Make a profile the current one",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if _, ok := cfg.Profiles[args[0]]; !ok {
+			return fmt.Errorf("no such profile %q", args[0])
+		}
+		cfg.Current = args[0]
+		return saveConfig(cfg)
+	}),
+}
+
+func init() {
+	configCommand.AddCommand(configGetCommand)
+	configCommand.AddCommand(configSetCommand)
+	configCommand.AddCommand(configListCommand)
+	configCommand.AddCommand(configUseCommand)
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+// setenv sets an environment variable, and returns a function restoring it.
+func setenv(key, value string) func() {
+	old := os.Getenv(key)
+	os.Setenv(key, value)
+	return func() { os.Setenv(key, old) }
+}
+
+func TestProfileName(t *testing.T) {
+	defer setenv("HUECONTROL_PROFILE", "")()
+	oldFlag := profileFlag
+	defer func() { profileFlag = oldFlag }()
+	profileFlag = ""
+
+	assert.Equal(t, defaultProfileName, config{}.profileName())
+	assert.Equal(t, "home", config{Current: "home"}.profileName())
+
+	os.Setenv("HUECONTROL_PROFILE", "office")
+	assert.Equal(t, "office", config{Current: "home"}.profileName())
+
+	profileFlag = "cabin"
+	assert.Equal(t, "cabin", config{Current: "home"}.profileName())
+}
+
+func TestProfileApplyEnv(t *testing.T) {
+	defer setenv("HUECONTROL_HOSTNAME", "10.0.0.2")()
+	defer setenv("HUECONTROL_SCHEME", "http")()
+	defer setenv("HUECONTROL_USERNAME", "")()
+
+	p := profile{Hostname: "10.0.0.1", Username: "user", BridgeID: "001788fffe123456"}
+	p.applyEnv()
+	assert.Equal(t, profile{Hostname: "10.0.0.2", Username: "user", BridgeID: "001788fffe123456", Scheme: "http"}, p)
+	assert.False(t, p.useTLS())
+}
+
+func TestProfileUseTLS(t *testing.T) {
+	tests := []struct {
+		p     profile
+		tls   bool
+		valid bool
+	}{
+		{profile{}, false, true},
+		{profile{BridgeID: "b"}, true, true},
+		{profile{BridgeID: "b", Scheme: "http"}, false, true},
+		{profile{BridgeID: "b", Scheme: "https"}, true, true},
+		{profile{Scheme: "https"}, true, false},
+		{profile{BridgeID: "b", Scheme: "ftp"}, false, false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.tls, test.p.useTLS(), "%#v", test.p)
+		assert.Equal(t, test.valid, test.p.validate() == nil, "%#v", test.p)
+	}
+}
+
+func TestLoadLegacyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "huecontrol")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer os.Chdir(wd)
+	assert.Nil(t, os.Chdir(dir))
+
+	path := filepath.Join(dir, "huecontrol", "config.json")
+	defer setenv("HUECONTROL_CONFIG", path)()
+
+	legacy := `{"hostname":"10.0.0.1","username":"user"}`
+	assert.Nil(t, ioutil.WriteFile(legacyConfigFile, []byte(legacy), 0600))
+
+	cfg, err := loadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]profile{defaultProfileName: {Hostname: "10.0.0.1", Username: "user"}}, cfg.Profiles)
+
+	// once a config file exists, the legacy file is ignored.
+	assert.Nil(t, saveConfig(config{Current: "home", Profiles: map[string]profile{"home": {Hostname: "10.0.0.9"}}}))
+	cfg, err = loadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, config{Current: "home", Profiles: map[string]profile{"home": {Hostname: "10.0.0.9"}}}, cfg)
+}
+
+func TestProbeHTTPS(t *testing.T) {
+	const bridgeID = "001788fffe123456"
+
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+	_, err := probeHTTPS(strings.TrimPrefix(plain.URL, "http://"), bridgeID)
+	assert.NotNil(t, err)
+
+	cert := bridgeCertificate(t, bridgeID)
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	fingerprint, err := probeHTTPS(host, bridgeID)
+	assert.Nil(t, err)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	assert.Equal(t, hue.CertificateFingerprint(parsed), fingerprint)
+
+	_, err = probeHTTPS(host, "001788fffe654321")
+	assert.NotNil(t, err)
+}
+
+func bridgeCertificate(t *testing.T, commonName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
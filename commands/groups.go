@@ -0,0 +1,28 @@
+package commands
+
+import "github.com/vincentcr/huecontrol/hue"
+
+var groupsCommand = resourceCommand("groups", "List and control groups", resource{
+	name: "group",
+	list: func(client *hue.Client) (interface{}, error) {
+		return client.GetGroups()
+	},
+	get: func(client *hue.Client, id string) (interface{}, error) {
+		return client.GetGroup(id)
+	},
+	resolve: func(r *hue.Resolver, selector string) (interface{}, []string, error) {
+		groups, err := r.ResolveGroups(selector)
+		ids := make([]string, len(groups))
+		for i, group := range groups {
+			ids[i] = group.ID
+		}
+		return groups, ids, err
+	},
+	resolveOne: func(r *hue.Resolver, selector string) (string, error) {
+		group, err := r.ResolveGroup(selector)
+		return group.ID, err
+	},
+	setState: (*hue.Client).SetGroupState,
+	rename:   (*hue.Client).RenameGroup,
+	scenes:   true,
+})
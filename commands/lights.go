@@ -0,0 +1,28 @@
+package commands
+
+import "github.com/vincentcr/huecontrol/hue"
+
+var lightsCommand = resourceCommand("lights", "List and control lights", resource{
+	name: "light",
+	list: func(client *hue.Client) (interface{}, error) {
+		return client.GetLights()
+	},
+	get: func(client *hue.Client, id string) (interface{}, error) {
+		return client.GetLight(id)
+	},
+	resolve: func(r *hue.Resolver, selector string) (interface{}, []string, error) {
+		lights, err := r.ResolveLights(selector)
+		ids := make([]string, len(lights))
+		for i, light := range lights {
+			ids[i] = light.ID
+		}
+		return lights, ids, err
+	},
+	resolveOne: func(r *hue.Resolver, selector string) (string, error) {
+		light, err := r.ResolveLight(selector)
+		return light.ID, err
+	},
+	adjustState: clampCT,
+	setState:    (*hue.Client).SetLightState,
+	rename:      (*hue.Client).RenameLight,
+})
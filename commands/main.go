@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"fmt"
 	"log"
 	"os"
 
@@ -8,15 +9,27 @@ import (
 )
 
 func Execute() {
-	rootCmd := &cobra.Command{Use: "htflood"}
+	rootCmd := &cobra.Command{Use: "huecontrol"}
 	setupCommands(rootCmd)
 	rootCmd.Execute()
 }
 
 func setupCommands(rootCmd *cobra.Command) {
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "configuration profile to use (default: current profile)")
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", "json", "output format: json, table, yaml, ndjson or template=<go template>")
+
 	rootCmd.AddCommand(lightsCommand)
 	rootCmd.AddCommand(groupsCommand)
 	rootCmd.AddCommand(schedulesCommand)
+	rootCmd.AddCommand(scenesCommand)
+	rootCmd.AddCommand(backupCommand)
+	rootCmd.AddCommand(restoreCommand)
+	rootCmd.AddCommand(pairCommand)
+	rootCmd.AddCommand(configCommand)
+	rootCmd.AddCommand(shellCommand)
+	rootCmd.AddCommand(watchCommand)
+	rootCmd.AddCommand(runCommand)
+	rootCmd.AddCommand(remoteTokenCommand)
 }
 
 func checkedRun(run func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) {
@@ -28,3 +41,10 @@ func checkedRun(run func(cmd *cobra.Command, args []string) error) func(cmd *cob
 		}
 	}
 }
+
+func expectArgs(cmd *cobra.Command, args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("usage: %v", cmd.UseLine())
+	}
+	return nil
+}
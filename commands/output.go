@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+const templateOutputPrefix = "template="
+
+// outputFlag is set by the global --output flag: json, table, yaml, ndjson or template=<text/template>.
+var outputFlag string
+
+func outputFormatted(obj interface{}) error {
+	return writeFormatted(os.Stdout, outputFlag, obj)
+}
+
+func writeFormatted(w io.Writer, format string, obj interface{}) error {
+	switch {
+	case format == "" || format == "json":
+		return writeJSON(w, obj)
+	case format == "ndjson":
+		return writeNDJSON(w, obj)
+	case format == "yaml":
+		return writeYAML(w, obj)
+	case format == "table":
+		return writeTable(w, obj)
+	case strings.HasPrefix(format, templateOutputPrefix):
+		return writeTemplate(w, strings.TrimPrefix(format, templateOutputPrefix), obj)
+	default:
+		return fmt.Errorf("unknown output format %q; expected json, table, yaml, ndjson or template=<template>", format)
+	}
+}
+
+func writeJSON(w io.Writer, obj interface{}) error {
+	bytes, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to format for output: %#v: %v", obj, err)
+	}
+	_, err = fmt.Fprintln(w, string(bytes))
+	return err
+}
+
+// items returns the elements of obj if it is a slice, or obj itself otherwise.
+func items(obj interface{}) []interface{} {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Slice {
+		return []interface{}{obj}
+	}
+	result := make([]interface{}, v.Len())
+	for i := range result {
+		result[i] = v.Index(i).Interface()
+	}
+	return result
+}
+
+func writeNDJSON(w io.Writer, obj interface{}) error {
+	encoder := json.NewEncoder(w)
+	for _, item := range items(obj) {
+		if err := encoder.Encode(item); err != nil {
+			return fmt.Errorf("unable to format for output: %#v: %v", item, err)
+		}
+	}
+	return nil
+}
+
+// writeTemplate executes the template once per item, e.g. -o 'template={{.ID}} {{.Name}}'.
+func writeTemplate(w io.Writer, text string, obj interface{}) error {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid output template: %v", err)
+	}
+	for _, item := range items(obj) {
+		if err := tmpl.Execute(w, item); err != nil {
+			return fmt.Errorf("unable to format for output: %v", err)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func writeTable(w io.Writer, obj interface{}) error {
+	header, rows, ok := tableRows(obj)
+	if !ok {
+		return writeJSON(w, obj)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func tableRows(obj interface{}) ([]string, [][]string, bool) {
+	var rows [][]string
+	switch v := obj.(type) {
+	case hue.Light:
+		return tableRows([]hue.Light{v})
+	case []hue.Light:
+		for _, light := range v {
+			state := light.State
+			rows = append(rows, []string{light.ID, light.Name, onOff(state.On), strconv.Itoa(int(state.Bri)),
+				colorDescription(state.ColorMode, state.Hue, state.Sat, state.XY, state.CT), yesNo(state.Reachable)})
+		}
+		return []string{"ID", "NAME", "ON", "BRI", "COLOR", "REACHABLE"}, rows, true
+	case hue.Group:
+		return tableRows([]hue.Group{v})
+	case []hue.Group:
+		for _, group := range v {
+			action := group.Action
+			rows = append(rows, []string{group.ID, group.Name, group.Type, onOff(action.On), strconv.Itoa(int(action.Bri)),
+				colorDescription("", action.Hue, action.Sat, action.XY, action.CT), strings.Join(group.Lights, ",")})
+		}
+		return []string{"ID", "NAME", "TYPE", "ON", "BRI", "COLOR", "LIGHTS"}, rows, true
+	case hue.Scene:
+		return tableRows([]hue.Scene{v})
+	case []hue.Scene:
+		for _, scene := range v {
+			rows = append(rows, []string{scene.ID, scene.Name, scene.Type, scene.Group, strings.Join(scene.Lights, ",")})
+		}
+		return []string{"ID", "NAME", "TYPE", "GROUP", "LIGHTS"}, rows, true
+	case hue.Schedule:
+		return tableRows([]hue.Schedule{v})
+	case []hue.Schedule:
+		for _, schedule := range v {
+			rows = append(rows, []string{schedule.ID, schedule.Name, schedule.LocalTime, schedule.Status,
+				schedule.Command.Method + " " + schedule.Command.Address})
+		}
+		return []string{"ID", "NAME", "TIME", "STATUS", "COMMAND"}, rows, true
+	default:
+		return nil, nil, false
+	}
+}
+
+// colorDescription describes the color in the mode the light is in; without a
+// mode, the first color attribute set is used.
+func colorDescription(mode string, h uint16, sat uint8, xy []float32, ct uint16) string {
+	switch {
+	case mode == "ct" || (mode == "" && ct != 0):
+		return fmt.Sprintf("ct %d", ct)
+	case mode == "xy" || (mode == "" && len(xy) == 2):
+		if len(xy) == 2 {
+			return fmt.Sprintf("xy %.4f,%.4f", xy[0], xy[1])
+		}
+	case mode == "hs" || (mode == "" && sat != 0):
+		return fmt.Sprintf("hs %d,%d", h, sat)
+	}
+	return "-"
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// writeYAML converts the JSON encoding of obj to YAML, preserving field order.
+func writeYAML(w io.Writer, obj interface{}) error {
+	encoded, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("unable to format for output: %#v: %v", obj, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	node, err := decodeYAMLNode(decoder)
+	if err != nil {
+		return fmt.Errorf("unable to format for output: %v", err)
+	}
+
+	var buf bytes.Buffer
+	node.write(&buf, 0)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+type yamlNode struct {
+	scalar string
+	isMap  bool
+	isList bool
+	keys   []string
+	values []yamlNode
+}
+
+func decodeYAMLNode(decoder *json.Decoder) (yamlNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return yamlNode{}, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		node := yamlNode{isMap: t == '{', isList: t == '['}
+		for decoder.More() {
+			if node.isMap {
+				key, err := decoder.Token()
+				if err != nil {
+					return node, err
+				}
+				node.keys = append(node.keys, yamlString(key.(string)))
+			}
+			value, err := decodeYAMLNode(decoder)
+			if err != nil {
+				return node, err
+			}
+			node.values = append(node.values, value)
+		}
+		_, err := decoder.Token()
+		return node, err
+	case string:
+		return yamlNode{scalar: yamlString(t)}, nil
+	case json.Number:
+		return yamlNode{scalar: t.String()}, nil
+	case bool:
+		return yamlNode{scalar: strconv.FormatBool(t)}, nil
+	default:
+		return yamlNode{scalar: "null"}, nil
+	}
+}
+
+func (node yamlNode) isScalar() bool {
+	return !node.isMap && !node.isList
+}
+
+func (node yamlNode) isEmpty() bool {
+	return !node.isScalar() && len(node.values) == 0
+}
+
+func (node yamlNode) write(buf *bytes.Buffer, indent int) {
+	prefix := strings.Repeat("  ", indent)
+	switch {
+	case node.isScalar():
+		buf.WriteString(node.scalar + "\n")
+	case node.isMap && node.isEmpty():
+		buf.WriteString("{}\n")
+	case node.isList && node.isEmpty():
+		buf.WriteString("[]\n")
+	case node.isMap:
+		for i, key := range node.keys {
+			buf.WriteString(prefix + key + ":")
+			node.values[i].writeNested(buf, indent+1)
+		}
+	case node.isList:
+		for _, value := range node.values {
+			if value.isMap && !value.isEmpty() {
+				// "- key: value", with the following keys aligned under the first one.
+				var item bytes.Buffer
+				value.write(&item, indent+1)
+				buf.WriteString(prefix + "- " + strings.TrimPrefix(item.String(), prefix+"  "))
+				continue
+			}
+			buf.WriteString(prefix + "-")
+			value.writeNested(buf, indent+1)
+		}
+	}
+}
+
+func (node yamlNode) writeNested(buf *bytes.Buffer, indent int) {
+	if node.isScalar() || node.isEmpty() {
+		buf.WriteString(" ")
+		node.write(buf, indent)
+	} else {
+		buf.WriteString("\n")
+		node.write(buf, indent)
+	}
+}
+
+// yamlString quotes strings YAML would otherwise read as another type or misparse.
+func yamlString(s string) string {
+	if s == "" || strings.ContainsAny(s, ":#{}[],&*!|>'\"%@`\n\t\\") ||
+		strings.TrimSpace(s) != s || strings.HasPrefix(s, "-") || strings.HasPrefix(s, "?") {
+		quoted, _ := json.Marshal(s)
+		return string(quoted)
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~", "y", "n":
+		return `"` + s + `"`
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return `"` + s + `"`
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+func TestYAMLString(t *testing.T) {
+	tests := map[string]string{
+		"":            `""`,
+		"kitchen":     `kitchen`,
+		"desk lamp":   `desk lamp`,
+		"on":          `"on"`,
+		"Off":         `"Off"`,
+		"yes":         `"yes"`,
+		"null":        `"null"`,
+		"~":           `"~"`,
+		"42":          `"42"`,
+		"1.5":         `"1.5"`,
+		"1e3":         `"1e3"`,
+		"a: b":        `"a: b"`,
+		"10:30":       `"10:30"`,
+		"#ff0000":     `"#ff0000"`,
+		"- item":      `"- item"`,
+		"?":           `"?"`,
+		" padded":     `" padded"`,
+		"say \"hi\"":  `"say \"hi\""`,
+		"two\nlines":  `"two\nlines"`,
+		"[1,2]":       `"[1,2]"`,
+		"/lights/1":   `/lights/1`,
+		"W124/T06:00": `"W124/T06:00"`,
+	}
+	for s, expected := range tests {
+		assert.Equal(t, expected, yamlString(s), "%q", s)
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	tests := []struct {
+		obj      interface{}
+		expected string
+	}{
+		{"on", "\"on\"\n"},
+		{42, "42\n"},
+		{nil, "null\n"},
+		{[]string{}, "[]\n"},
+		{map[string]interface{}{}, "{}\n"},
+		{
+			struct {
+				Name   string
+				On     bool
+				Bri    int
+				Lights []string
+				Empty  string
+				Mode   string
+			}{"Living: main", true, 254, []string{"1", "2"}, "", "on"},
+			"Name: \"Living: main\"\n\"On\": true\nBri: 254\nLights:\n  - \"1\"\n  - \"2\"\nEmpty: \"\"\nMode: \"on\"\n",
+		},
+		{
+			[]map[string]interface{}{{"id": "1", "tags": []string{}}, {}},
+			"- id: \"1\"\n  tags: []\n- {}\n",
+		},
+		{
+			map[string]interface{}{"state": map[string]interface{}{"xy": []float64{0.3, 0.4}}},
+			"state:\n  xy:\n    - 0.3\n    - 0.4\n",
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		assert.Nil(t, writeYAML(&buf, test.obj))
+		assert.Equal(t, test.expected, buf.String(), "%#v", test.obj)
+	}
+}
+
+func TestTableRows(t *testing.T) {
+	var light hue.Light
+	light.ID, light.Name = "1", "Desk"
+	light.State.On, light.State.Bri, light.State.Reachable = true, 200, true
+	light.State.ColorMode, light.State.CT = "ct", 366
+
+	var group hue.Group
+	group.ID, group.Name, group.Type, group.Lights = "2", "Office", "Room", []string{"1", "3"}
+	group.Action.XY = []float32{0.3, 0.4}
+
+	tests := []struct {
+		obj    interface{}
+		header []string
+		rows   [][]string
+	}{
+		{
+			light,
+			[]string{"ID", "NAME", "ON", "BRI", "COLOR", "REACHABLE"},
+			[][]string{{"1", "Desk", "on", "200", "ct 366", "yes"}},
+		},
+		{
+			[]hue.Group{group},
+			[]string{"ID", "NAME", "TYPE", "ON", "BRI", "COLOR", "LIGHTS"},
+			[][]string{{"2", "Office", "Room", "off", "0", "xy 0.3000,0.4000", "1,3"}},
+		},
+		{
+			[]hue.Scene{{ID: "abc", Name: "Relax", Type: "GroupScene", Group: "2"}},
+			[]string{"ID", "NAME", "TYPE", "GROUP", "LIGHTS"},
+			[][]string{{"abc", "Relax", "GroupScene", "2", ""}},
+		},
+		{
+			hue.Schedule{ID: "3", Name: "Wake", LocalTime: "W124/T06:00:00", Status: "enabled",
+				Command: hue.Command{Method: "PUT", Address: "/api/user/groups/2/action"}},
+			[]string{"ID", "NAME", "TIME", "STATUS", "COMMAND"},
+			[][]string{{"3", "Wake", "W124/T06:00:00", "enabled", "PUT /api/user/groups/2/action"}},
+		},
+	}
+	for _, test := range tests {
+		header, rows, ok := tableRows(test.obj)
+		assert.True(t, ok)
+		assert.Equal(t, test.header, header)
+		assert.Equal(t, test.rows, rows)
+	}
+
+	_, _, ok := tableRows(hue.Sensor{})
+	assert.False(t, ok)
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeTable(&buf, []hue.Light{}))
+	assert.Equal(t, "ID  NAME  ON  BRI  COLOR  REACHABLE\n", buf.String())
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+const (
+	linkButtonPollInterval = time.Second
+	httpsProbeTimeout      = 5 * time.Second
+)
+
+var pairCommand = &cobra.Command{
+	Use:   "pair",
+	Short: "Discover a bridge, register a user on it and save it to the config",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		host, _ := cmd.Flags().GetString("host")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		in := bufio.NewReader(os.Stdin)
+
+		if host == "" {
+			bridge, err := chooseBridge(in)
+			if err != nil {
+				return err
+			}
+			host = bridge.IP
+		}
+
+		bridgeConfig, err := hue.GetPublicConfig(host)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Press the link button on bridge %v (%v)...\n", bridgeConfig.Name, host)
+		username, err := waitForUser(host, timeout)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		name := cfg.profileName()
+		bridgeID := strings.ToLower(bridgeConfig.BridgeID)
+		paired := profile{Hostname: host, Username: username, BridgeID: bridgeID, Scheme: schemeHTTPS}
+		paired.CertFingerprint, err = probeHTTPS(host, bridgeID)
+		if err != nil {
+			fmt.Printf("The bridge does not serve https (%v); using http\n", err)
+			paired.Scheme, paired.CertFingerprint = schemeHTTP, ""
+		}
+		err = updateProfile(name, func(p *profile) {
+			*p = paired
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Paired with bridge %v; saved as profile %q\n", bridgeID, name)
+		return nil
+	}),
+}
+
+func init() {
+	pairCommand.Flags().String("host", "", "address of the bridge; skips discovery")
+	pairCommand.Flags().Duration("timeout", 60*time.Second, "how long to wait for the link button")
+}
+
+func chooseBridge(in *bufio.Reader) (hue.BridgeInfo, error) {
+	bridges, err := hue.DiscoverBridges()
+	if err != nil {
+		return hue.BridgeInfo{}, err
+	}
+
+	switch len(bridges) {
+	case 0:
+		return hue.BridgeInfo{}, fmt.Errorf("no bridge found on the network; use --host to specify its address")
+	case 1:
+		fmt.Printf("Found bridge %v at %v\n", bridges[0].ID, bridges[0].IP)
+		return bridges[0], nil
+	}
+
+	fmt.Println("Found bridges:")
+	for i, bridge := range bridges {
+		fmt.Printf("  %d) %v at %v\n", i+1, bridge.ID, bridge.IP)
+	}
+	for {
+		fmt.Printf("Choose a bridge [1-%d]: ", len(bridges))
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return hue.BridgeInfo{}, fmt.Errorf("no bridge chosen: %v", err)
+		}
+		choice, err := strconv.Atoi(strings.TrimSpace(line))
+		if err == nil && choice >= 1 && choice <= len(bridges) {
+			return bridges[choice-1], nil
+		}
+	}
+}
+
+// probeHTTPS connects to the bridge over TLS, and returns the fingerprint of
+// its certificate if it is the certificate of the bridge.
+func probeHTTPS(host, bridgeID string) (string, error) {
+	addr := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		addr = net.JoinHostPort(host, "443")
+	}
+
+	var fingerprint string
+	pin := hue.CertificatePin{
+		BridgeID: bridgeID,
+		OnFirstUse: func(actual string) error {
+			fingerprint = actual
+			return nil
+		},
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: httpsProbeTimeout}, "tcp", addr, pin.TLSConfig())
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return fingerprint, nil
+}
+
+func waitForUser(host string, timeout time.Duration) (string, error) {
+	deviceType := "huecontrol#" + deviceName()
+	deadline := time.Now().Add(timeout)
+	for {
+		username, err := hue.RegisterUser(host, deviceType)
+		if err == nil {
+			return username, nil
+		} else if err != hue.ErrLinkButtonNotPressed {
+			return "", err
+		} else if time.Now().After(deadline) {
+			return "", fmt.Errorf("link button not pressed after %v", timeout)
+		}
+		time.Sleep(linkButtonPollInterval)
+	}
+}
+
+// deviceName is the name the bridge shows for the registered user; the bridge
+// limits it to 19 characters.
+func deviceName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		name = "cli"
+	}
+	if len(name) > 19 {
+		name = name[:19]
+	}
+	return name
+}
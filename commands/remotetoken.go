@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/howeyc/gopass"
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/api/services"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+var remoteTokenCommand = &cobra.Command{
+	Use:   "remote-token",
+	Short: "Get a token to control the bridge through the meethue remote API",
+	Long: `Get a token to control the bridge through the meethue remote API, by
+logging in to meethue.com with your Philips account. The token is printed, so
+that it can be linked to the bridge in the API server.`,
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		bridgeID, _ := cmd.Flags().GetString("bridge-id")
+		email, _ := cmd.Flags().GetString("email")
+		baseURL, _ := cmd.Flags().GetString("base-url")
+
+		if bridgeID == "" {
+			var err error
+			if bridgeID, err = profileBridgeID(); err != nil {
+				return err
+			}
+		}
+
+		if email == "" {
+			fmt.Fprint(os.Stderr, "email: ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil {
+				return fmt.Errorf("unable to read email: %v", err)
+			}
+			email = strings.TrimSpace(line)
+		}
+		fmt.Fprint(os.Stderr, "password: ")
+		password := string(gopass.GetPasswd())
+
+		token, err := services.GetTokenAtBaseURL(baseURL, services.HueTokenCredentials{
+			DeviceID: bridgeID,
+			Username: email,
+			Password: password,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println(token)
+		return nil
+	}),
+}
+
+func init() {
+	remoteTokenCommand.Flags().String("bridge-id", "", "ID of the bridge (default: the bridge of the current profile)")
+	remoteTokenCommand.Flags().String("email", "", "email of the Philips account; prompted for if not given")
+	remoteTokenCommand.Flags().String("base-url", services.BASE_URL, "address of the meethue site")
+	remoteTokenCommand.Flags().MarkHidden("base-url")
+}
+
+// profileBridgeID returns the ID of the profile's bridge, asking the bridge if
+// the profile predates pair saving it.
+func profileBridgeID() (string, error) {
+	name, p, err := loadProfile()
+	if err != nil {
+		return "", err
+	} else if p.BridgeID != "" {
+		return p.BridgeID, nil
+	} else if p.Hostname == "" {
+		return "", fmt.Errorf("no bridge configured for profile %q; run huecontrol pair or use --bridge-id", name)
+	}
+
+	config, err := hue.GetPublicConfig(p.Hostname)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(config.BridgeID), nil
+}
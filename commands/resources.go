@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+const targetHelp = `A target is an ID, a name, a glob such as "kitchen*", a regular expression
+such as "/^desk/", "room:<name>", "zone:<name>", "group:<name>" or "all".`
+
+// resource is what lights and groups have in common from the CLI's point of view.
+type resource struct {
+	name string
+	list func(client *hue.Client) (interface{}, error)
+	get  func(client *hue.Client, id string) (interface{}, error)
+	// resolve returns the objects matching a target, as a slice, and their IDs.
+	resolve func(r *hue.Resolver, selector string) (interface{}, []string, error)
+	// resolveOne returns the ID of the only object matching a target.
+	resolveOne func(r *hue.Resolver, selector string) (string, error)
+	setState   func(client *hue.Client, id string, state hue.StateUpdate) error
+	// adjustState, if set, adapts a state change to the object it is sent to.
+	adjustState func(r *hue.Resolver, id string, state hue.StateUpdate) hue.StateUpdate
+	rename      func(client *hue.Client, id string, name string) error
+	// scenes is true if set accepts --scene.
+	scenes bool
+}
+
+func resourceCommand(use string, short string, res resource) *cobra.Command {
+	cmd := &cobra.Command{Use: use, Short: short, Long: short + ".\n\n" + targetHelp}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list [target]",
+		Short: "List all " + res.name + "s, or those matching a target",
+		Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("usage: %v", cmd.UseLine())
+			}
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				objs, err := res.list(client)
+				if err != nil {
+					return err
+				}
+				return outputFormatted(objs)
+			}
+
+			resolver, err := client.NewResolver()
+			if err != nil {
+				return err
+			}
+			objs, _, err := res.resolve(resolver, args[0])
+			if err != nil {
+				return err
+			}
+			return outputFormatted(objs)
+		}),
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <target>",
+		Short: "Show a " + res.name,
+		Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+			if err := expectArgs(cmd, args, 1); err != nil {
+				return err
+			}
+			client, id, err := resolveOne(res, args[0])
+			if err != nil {
+				return err
+			}
+			obj, err := res.get(client, id)
+			if err != nil {
+				return err
+			}
+			return outputFormatted(obj)
+		}),
+	})
+
+	setCmd := &cobra.Command{
+		Use:   "set <target>",
+		Short: "Change the state of matching " + res.name + "s",
+		Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+			if err := expectArgs(cmd, args, 1); err != nil {
+				return err
+			}
+			state, err := stateFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			return setStates(res, args[0], state)
+		}),
+	}
+	addStateFlags(setCmd)
+	if res.scenes {
+		setCmd.Flags().String("scene", "", "recall a scene by ID")
+	}
+	cmd.AddCommand(setCmd)
+
+	for _, on := range []bool{true, false} {
+		use, short := "on <target>", "Turn matching "+res.name+"s on"
+		if !on {
+			use, short = "off <target>", "Turn matching "+res.name+"s off"
+		}
+		state := hue.StateUpdate{On: boolPtr(on)}
+		cmd.AddCommand(&cobra.Command{
+			Use:   use,
+			Short: short,
+			Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+				if err := expectArgs(cmd, args, 1); err != nil {
+					return err
+				}
+				return setStates(res, args[0], state)
+			}),
+		})
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rename <target> <name>",
+		Short: "Rename a " + res.name,
+		Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+			if err := expectArgs(cmd, args, 2); err != nil {
+				return err
+			}
+			client, id, err := resolveOne(res, args[0])
+			if err != nil {
+				return err
+			}
+			return res.rename(client, id, args[1])
+		}),
+	})
+
+	return cmd
+}
+
+func resolveOne(res resource, selector string) (*hue.Client, string, error) {
+	client, err := newClient()
+	if err != nil {
+		return nil, "", err
+	}
+	resolver, err := client.NewResolver()
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := res.resolveOne(resolver, selector)
+	return client, id, err
+}
+
+func setStates(res resource, selector string, state hue.StateUpdate) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	resolver, err := client.NewResolver()
+	if err != nil {
+		return err
+	}
+	_, ids, err := res.resolve(resolver, selector)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		state := state
+		if res.adjustState != nil {
+			state = res.adjustState(resolver, id, state)
+		}
+		if err := res.setState(client, id, state); err != nil {
+			return fmt.Errorf("%v %v: %v", res.name, id, err)
+		}
+	}
+	return nil
+}
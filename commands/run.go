@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+var runCommand = &cobra.Command{
+	Use:   "run <file>",
+	Short: "Run a timeline of light changes",
+	Long: `Run a timeline of light changes. Interrupting the run with Ctrl-C puts
+the lights back in the state they were in before it started.
+
+` + timelineHelp,
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 1); err != nil {
+			return err
+		}
+
+		assignments, _ := cmd.Flags().GetStringArray("var")
+		vars := make(map[string]string)
+		for _, assignment := range assignments {
+			parts := strings.SplitN(assignment, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid variable %q: expected name=value", assignment)
+			}
+			vars[parts[0]] = parts[1]
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("unable to open timeline: %v", err)
+		}
+		defer file.Close()
+		tl, err := parseTimeline(file, vars)
+		if err != nil {
+			return fmt.Errorf("%v: %v", args[0], err)
+		}
+
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		resolver, err := tl.bind(client)
+		if err != nil {
+			return fmt.Errorf("%v: %v", args[0], err)
+		}
+
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			tl.describe(os.Stdout)
+			return nil
+		}
+
+		saved, err := saveLightStates(client, tl.lightIDs(resolver))
+		if err != nil {
+			return err
+		}
+
+		stop := make(chan struct{})
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			close(stop)
+		}()
+
+		if tl.run(client, resolver, os.Stdout, stop) {
+			return nil
+		}
+		fmt.Printf("interrupted; restoring %d lights\n", len(saved))
+		return restoreLightStates(client, saved)
+	}),
+}
+
+func init() {
+	runCommand.Flags().Bool("dry-run", false, "check the timeline and show what it would do, without changing anything")
+	runCommand.Flags().StringArray("var", nil, "set a variable, overriding the timeline's own definition (name=value)")
+}
+
+func saveLightStates(client *hue.Client, ids []string) (map[string]hue.StateUpdate, error) {
+	lights, err := client.GetLights()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool)
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	saved := make(map[string]hue.StateUpdate)
+	for _, light := range lights {
+		if wanted[light.ID] {
+			saved[light.ID] = light.CurrentState()
+		}
+	}
+	return saved, nil
+}
+
+func restoreLightStates(client *hue.Client, saved map[string]hue.StateUpdate) error {
+	var failed []string
+	for id, state := range saved {
+		if err := client.SetLightState(id, state); err != nil {
+			failed = append(failed, fmt.Sprintf("light %v: %v", id, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to restore %v", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+var scenesCommand = &cobra.Command{
+	Use:     "scenes",
+	Aliases: []string{"scene"},
+	Short:   "Capture, apply and manage scenes",
+	Long: `Capture, apply and manage scenes.
+
+Scenes are referred to by ID, name, glob or regular expression. Groups are
+targets as described in "huecontrol groups --help".`,
+}
+
+var scenesListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "List all scenes",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		client, resolver, err := newSceneResolver()
+		if err != nil {
+			return err
+		}
+		scenes := resolver.Scenes
+
+		groupSelector, _ := cmd.Flags().GetString("group")
+		if groupSelector != "" {
+			group, err := resolveGroupFlag(client, groupSelector)
+			if err != nil {
+				return err
+			}
+			scenes = nil
+			for _, scene := range resolver.Scenes {
+				if scene.Group == group.ID {
+					scenes = append(scenes, scene)
+				}
+			}
+		}
+		return outputFormatted(scenes)
+	}),
+}
+
+var scenesGetCommand = &cobra.Command{
+	Use:   "get <scene>",
+	Short: "Show a scene with its light states",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		client, scene, err := getScene(args[0])
+		if err != nil {
+			return err
+		}
+		scene, err = client.GetScene(scene.ID)
+		if err != nil {
+			return err
+		}
+		return outputFormatted(scene)
+	}),
+}
+
+var scenesCaptureCommand = &cobra.Command{
+	Use:   "capture <name>",
+	Short: "Create a scene from the current state of a group's lights",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		groupSelector, _ := cmd.Flags().GetString("group")
+		if groupSelector == "" {
+			return fmt.Errorf("capture: --group is required")
+		}
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		group, err := resolveGroupFlag(client, groupSelector)
+		if err != nil {
+			return err
+		}
+
+		id, err := client.CaptureScene(args[0], group)
+		if err != nil {
+			return err
+		}
+		scene, err := client.GetScene(id)
+		if err != nil {
+			return err
+		}
+		return outputFormatted(scene)
+	}),
+}
+
+var scenesApplyCommand = &cobra.Command{
+	Use:   "apply <scene>",
+	Short: "Recall a scene",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		client, scene, err := getScene(args[0])
+		if err != nil {
+			return err
+		}
+
+		groupID := scene.Group
+		if groupID == "" {
+			groupID = hue.AllLightsGroupID
+		}
+		if groupSelector, _ := cmd.Flags().GetString("group"); groupSelector != "" {
+			group, err := resolveGroupFlag(client, groupSelector)
+			if err != nil {
+				return err
+			}
+			groupID = group.ID
+		}
+		return client.RecallScene(groupID, scene.ID)
+	}),
+}
+
+var scenesDeleteCommand = &cobra.Command{
+	Use:   "delete <scene>",
+	Short: "Delete a scene",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		client, scene, err := getScene(args[0])
+		if err != nil {
+			return err
+		}
+		return client.DeleteScene(scene.ID)
+	}),
+}
+
+var scenesExportCommand = &cobra.Command{
+	Use:   "export <scene> [file]",
+	Short: "Write a scene and its light states to a JSON file",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 && len(args) != 2 {
+			return fmt.Errorf("usage: %v", cmd.UseLine())
+		}
+		client, scene, err := getScene(args[0])
+		if err != nil {
+			return err
+		}
+		scene, err = client.GetScene(scene.ID)
+		if err != nil {
+			return err
+		}
+
+		contents, err := json.MarshalIndent(scene, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to encode scene: %v", err)
+		}
+		contents = append(contents, '\n')
+
+		if len(args) == 1 {
+			_, err = os.Stdout.Write(contents)
+			return err
+		}
+		return ioutil.WriteFile(args[1], contents, 0644)
+	}),
+}
+
+var scenesImportCommand = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Create a scene from a JSON file written by export",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		contents, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("unable to read scene file: %v", err)
+		}
+		var scene hue.Scene
+		if err := json.Unmarshal(contents, &scene); err != nil {
+			return fmt.Errorf("unable to parse scene file: %v", err)
+		}
+		if len(scene.LightStates) == 0 {
+			return fmt.Errorf("scene file %v has no light states", args[0])
+		}
+
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		if name, _ := cmd.Flags().GetString("name"); name != "" {
+			scene.Name = name
+		}
+		if groupSelector, _ := cmd.Flags().GetString("group"); groupSelector != "" {
+			group, err := resolveGroupFlag(client, groupSelector)
+			if err != nil {
+				return err
+			}
+			scene.Type, scene.Group = hue.GroupScene, group.ID
+		}
+		if scene.Type != hue.GroupScene && len(scene.Lights) == 0 {
+			for id := range scene.LightStates {
+				scene.Lights = append(scene.Lights, id)
+			}
+		}
+
+		id, err := client.CreateScene(scene)
+		if err != nil {
+			return err
+		}
+		scene, err = client.GetScene(id)
+		if err != nil {
+			return err
+		}
+		return outputFormatted(scene)
+	}),
+}
+
+func init() {
+	scenesListCommand.Flags().String("group", "", "only list the scenes of this group")
+	scenesCaptureCommand.Flags().String("group", "", "group whose lights are captured, or all")
+	scenesApplyCommand.Flags().String("group", "", "apply to this group instead of the scene's own")
+	scenesImportCommand.Flags().String("name", "", "name of the new scene (default: the name in the file)")
+	scenesImportCommand.Flags().String("group", "", "group of the new scene (default: the group in the file)")
+
+	scenesCommand.AddCommand(scenesListCommand)
+	scenesCommand.AddCommand(scenesGetCommand)
+	scenesCommand.AddCommand(scenesCaptureCommand)
+	scenesCommand.AddCommand(scenesApplyCommand)
+	scenesCommand.AddCommand(scenesDeleteCommand)
+	scenesCommand.AddCommand(scenesExportCommand)
+	scenesCommand.AddCommand(scenesImportCommand)
+}
+
+func newSceneResolver() (*hue.Client, *hue.Resolver, error) {
+	client, err := newClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	scenes, err := client.GetScenes()
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, &hue.Resolver{Scenes: scenes}, nil
+}
+
+func getScene(selector string) (*hue.Client, hue.Scene, error) {
+	client, resolver, err := newSceneResolver()
+	if err != nil {
+		return nil, hue.Scene{}, err
+	}
+	scene, err := resolver.ResolveScene(selector)
+	return client, scene, err
+}
+
+func resolveGroupFlag(client *hue.Client, selector string) (hue.Group, error) {
+	resolver, err := client.NewResolver()
+	if err != nil {
+		return hue.Group{}, err
+	}
+	return resolver.ResolveGroup(selector)
+}
@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+var schedulesCommand = &cobra.Command{
+	Use:   "schedules",
+	Short: "Manage schedules",
+}
+
+var schedulesListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "List all schedules",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		schedules, err := client.GetSchedules()
+		if err != nil {
+			return err
+		}
+		return outputFormatted(schedules)
+	}),
+}
+
+var schedulesGetCommand = &cobra.Command{
+	Use:   "get <id>",
+	Short: "Show a schedule",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		schedule, err := client.GetSchedule(args[0])
+		if err != nil {
+			return err
+		}
+		return outputFormatted(schedule)
+	}),
+}
+
+var schedulesCreateCommand = &cobra.Command{
+	Use:   "create",
+	Short: "Create a schedule",
+	Long: `Create a schedule that sends a command to the bridge at a given time.
+
+Example: turn off all lights every weekday at 11pm
+  huecontrol schedules create --name bedtime --time W124/T23:00:00 \
+    --address /groups/0/action --body '{"on":false}'`,
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		schedule, err := scheduleFromFlags(cmd, client)
+		if err != nil {
+			return err
+		}
+		id, err := client.CreateSchedule(schedule)
+		if err != nil {
+			return err
+		}
+		schedule.ID = id
+		return outputFormatted(schedule)
+	}),
+}
+
+var schedulesDeleteCommand = &cobra.Command{
+	Use:   "delete <id>",
+	Short: "Delete a schedule",
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if err := expectArgs(cmd, args, 1); err != nil {
+			return err
+		}
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		return client.DeleteSchedule(args[0])
+	}),
+}
+
+func scheduleStatusCommand(use string, short string, status string) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+			if err := expectArgs(cmd, args, 1); err != nil {
+				return err
+			}
+			client, err := newClient()
+			if err != nil {
+				return err
+			}
+			return client.SetScheduleStatus(args[0], status)
+		}),
+	}
+}
+
+func init() {
+	flags := schedulesCreateCommand.Flags()
+	flags.String("name", "", "schedule name")
+	flags.String("description", "", "schedule description")
+	flags.String("time", "", "local time pattern, e.g. 2015-10-10T06:00:00, W124/T06:00:00 or PT00:10:00")
+	flags.String("address", "", "resource to send the command to, e.g. /groups/0/action")
+	flags.String("method", "PUT", "HTTP method of the command")
+	flags.String("body", "{}", "JSON body of the command")
+	flags.Bool("autodelete", false, "delete the schedule once it has run")
+
+	schedulesCommand.AddCommand(schedulesListCommand)
+	schedulesCommand.AddCommand(schedulesGetCommand)
+	schedulesCommand.AddCommand(schedulesCreateCommand)
+	schedulesCommand.AddCommand(schedulesDeleteCommand)
+	schedulesCommand.AddCommand(scheduleStatusCommand("enable <id>", "Enable a schedule", "enabled"))
+	schedulesCommand.AddCommand(scheduleStatusCommand("disable <id>", "Disable a schedule", "disabled"))
+}
+
+func scheduleFromFlags(cmd *cobra.Command, client *hue.Client) (hue.Schedule, error) {
+	flags := cmd.Flags()
+	var schedule hue.Schedule
+	schedule.Name, _ = flags.GetString("name")
+	schedule.Description, _ = flags.GetString("description")
+	schedule.LocalTime, _ = flags.GetString("time")
+	schedule.AutoDelete, _ = flags.GetBool("autodelete")
+	schedule.Command.Method, _ = flags.GetString("method")
+
+	address, _ := flags.GetString("address")
+	if schedule.LocalTime == "" || address == "" {
+		return schedule, fmt.Errorf("--time and --address are required")
+	}
+	// the bridge expects the full path of the resource, including the username.
+	if !strings.HasPrefix(address, "/api/") {
+		address = "/api/" + client.Username + "/" + strings.TrimPrefix(address, "/")
+	}
+	schedule.Command.Address = address
+
+	body, _ := flags.GetString("body")
+	if err := json.Unmarshal([]byte(body), &schedule.Command.Body); err != nil {
+		return schedule, fmt.Errorf("invalid --body %q: %v", body, err)
+	}
+
+	return schedule, nil
+}
@@ -0,0 +1,529 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/hue"
+	"github.com/vincentcr/huecontrol/hue/color"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const keyCtrlC = 3
+
+const shellHelp = `Commands:
+  <target> <action>...   change a light or group, e.g. "kitchen bri 50%", "all off",
+                         "living room" scene relax transition 20
+  lights, groups, scenes list them with their current state
+  refresh                reload light, group and scene names from the bridge
+  help                   show this help
+  exit                   leave the shell (or Ctrl-D)
+
+Targets are IDs, names, globs such as "kitchen*", regular expressions such as
+"/^desk/", "room:<name>", "zone:<name>" or "all". Groups win when a target
+matches both a group and lights. Quote names that contain spaces.
+
+Actions:
+  on, off                turn on or off
+  bri <1-254|N%|+N%>     brightness, absolute or relative
+  color <color>          a color or white temperature, e.g. orange, #ff8800, warm
+  hue <0-65535|Ndeg>     hue
+  sat <0-254|N%>         saturation
+  ct <mireds|NK|name>    color temperature, e.g. 370, 2700K or warm
+  xy <x,y>               CIE color coordinates
+  alert <select|lselect|none>
+  effect <colorloop|none>
+  transition <N>         transition time in tenths of a second
+  scene <name>           recall a scene (groups only)
+  rename <name>          rename the target
+  show                   show the target's current state
+
+Tab completes names and actions.
+`
+
+var shellCommand = &cobra.Command{
+	Use:   "shell",
+	Short: "Control the bridge from an interactive shell",
+	Long: `Start an interactive shell connected to the bridge, with line editing,
+history and tab completion of light, group and scene names. Type "help" in
+the shell for the list of commands. When standard input is not a terminal,
+commands are read from it one per line.`,
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		name, _, err := loadProfile()
+		if err != nil {
+			return err
+		}
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		sh := &shell{client: client, out: os.Stdout, format: "table"}
+		if cmd.Flag("output").Changed {
+			sh.format = outputFlag
+		}
+		if err := sh.refresh(); err != nil {
+			return err
+		}
+
+		fd := int(os.Stdin.Fd())
+		if !terminal.IsTerminal(fd) {
+			return sh.runScript(os.Stdin)
+		}
+		return sh.runInteractive(fd, "hue:"+name+"> ")
+	}),
+}
+
+// shell runs commands against a single client, caching names for completion.
+type shell struct {
+	client *hue.Client
+	out    io.Writer
+	format string
+	lights []hue.Light
+	groups []hue.Group
+	scenes []hue.Scene
+}
+
+func (sh *shell) runInteractive(fd int, prompt string) error {
+	oldState, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("unable to set up terminal: %v", err)
+	}
+	defer terminal.Restore(fd, oldState)
+	// request logs, which lack the carriage returns raw mode needs, would
+	// break up the prompt and the line being edited.
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	term := terminal.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, prompt)
+	term.AutoCompleteCallback = sh.complete
+	sh.out = term
+
+	fmt.Fprintf(term, "Connected to %v. Type \"help\" for help.\n", sh.client.Hostname)
+	for {
+		line, err := term.ReadLine()
+		if err == io.EOF {
+			fmt.Fprintln(term)
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to read input: %v", err)
+		}
+
+		exit, err := sh.execute(line)
+		if err != nil {
+			fmt.Fprintf(term, "error: %v\n", err)
+		}
+		if exit {
+			return nil
+		}
+	}
+}
+
+func (sh *shell) runScript(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		exit, err := sh.execute(scanner.Text())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		if exit {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func (sh *shell) refresh() error {
+	var err error
+	if sh.lights, err = sh.client.GetLights(); err != nil {
+		return err
+	}
+	if sh.groups, err = sh.client.GetGroups(); err != nil {
+		return err
+	}
+	sh.scenes, err = sh.client.GetScenes()
+	return err
+}
+
+// execute runs one command line, returning true when the shell should exit.
+func (sh *shell) execute(line string) (bool, error) {
+	words, err := splitWords(line)
+	if err != nil || len(words) == 0 {
+		return false, err
+	}
+
+	switch strings.ToLower(words[0]) {
+	case "exit", "quit":
+		return true, nil
+	case "help", "?":
+		fmt.Fprint(sh.out, shellHelp)
+		return false, nil
+	case "refresh":
+		if err := sh.refresh(); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(sh.out, "%d lights, %d groups, %d scenes\n", len(sh.lights), len(sh.groups), len(sh.scenes))
+		return false, nil
+	case "lights":
+		lights, err := sh.client.GetLights()
+		if err != nil {
+			return false, err
+		}
+		sh.lights = lights
+		return false, writeFormatted(sh.out, sh.format, lights)
+	case "groups":
+		groups, err := sh.client.GetGroups()
+		if err != nil {
+			return false, err
+		}
+		sh.groups = groups
+		return false, writeFormatted(sh.out, sh.format, groups)
+	case "scenes":
+		scenes, err := sh.client.GetScenes()
+		if err != nil {
+			return false, err
+		}
+		sh.scenes = scenes
+		return false, writeFormatted(sh.out, sh.format, scenes)
+	}
+
+	target, err := sh.resolveTarget(words[0])
+	if err != nil {
+		return false, err
+	}
+	if len(words) == 1 {
+		return false, sh.show(target)
+	}
+	return false, sh.apply(target, words[1:])
+}
+
+func (sh *shell) resolver() *hue.Resolver {
+	return hue.NewResolver(sh.lights, sh.groups)
+}
+
+func (sh *shell) resolveTarget(selector string) (target, error) {
+	t, err := resolveTarget(sh.resolver(), selector)
+	if err != nil {
+		return t, fmt.Errorf("%v; try \"refresh\"", err)
+	}
+	return t, nil
+}
+
+// resolveScene prefers the scenes of the target group, since scene names are
+// often reused across rooms.
+func (sh *shell) resolveScene(target target, selector string) (string, error) {
+	resolver := &hue.Resolver{Scenes: sh.scenes}
+	scenes, err := resolver.ResolveScenes(selector)
+	if err != nil {
+		return "", err
+	}
+	for _, scene := range scenes {
+		if len(scenes) == 1 || scene.Group == target.ids[0] {
+			return scene.ID, nil
+		}
+	}
+	_, err = resolver.ResolveScene(selector)
+	return "", err
+}
+
+func (sh *shell) show(target target) error {
+	if target.isGroup {
+		group, err := sh.client.GetGroup(target.ids[0])
+		if err != nil {
+			return err
+		}
+		return writeFormatted(sh.out, sh.format, group)
+	}
+	if len(target.ids) == 1 {
+		light, err := sh.client.GetLight(target.ids[0])
+		if err != nil {
+			return err
+		}
+		return writeFormatted(sh.out, sh.format, light)
+	}
+
+	lights, err := sh.client.GetLights()
+	if err != nil {
+		return err
+	}
+	selected := make(map[string]bool)
+	for _, id := range target.ids {
+		selected[id] = true
+	}
+	var matched []hue.Light
+	for _, light := range lights {
+		if selected[light.ID] {
+			matched = append(matched, light)
+		}
+	}
+	return writeFormatted(sh.out, sh.format, matched)
+}
+
+// apply parses actions such as "on bri 50% transition 10" and sends them as
+// a single state change.
+func (sh *shell) apply(target target, actions []string) error {
+	var state hue.StateUpdate
+	changed := false
+
+	for i := 0; i < len(actions); i++ {
+		action := strings.ToLower(actions[i])
+		if action == "on" || action == "off" {
+			state.On = boolPtr(action == "on")
+			changed = true
+			continue
+		}
+		if action == "show" {
+			if changed {
+				if err := target.setState(sh.client, sh.resolver(), state); err != nil {
+					return err
+				}
+				state, changed = hue.StateUpdate{}, false
+			}
+			if err := sh.show(target); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if i+1 >= len(actions) {
+			return fmt.Errorf("%v: missing value", action)
+		}
+		i++
+		value := actions[i]
+
+		var err error
+		switch action {
+		case "bri", "hue", "sat", "ct", "xy", "color", "alert", "effect":
+			err = applySetting(&state, action, value)
+		case "transition":
+			var transition uint16
+			transition, err = parseUint16(value, 65535)
+			state.TransitionTime = &transition
+		case "scene":
+			if !target.isGroup {
+				return fmt.Errorf("scenes can only be recalled on groups")
+			}
+			state.Scene, err = sh.resolveScene(target, value)
+		case "rename":
+			if err := sh.rename(target, value); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("unknown action %q; type \"help\" for help", action)
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", action, err)
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return target.setState(sh.client, sh.resolver(), state)
+}
+
+func (sh *shell) rename(target target, name string) error {
+	var err error
+	if target.isGroup {
+		err = sh.client.RenameGroup(target.ids[0], name)
+	} else if len(target.ids) == 1 {
+		err = sh.client.RenameLight(target.ids[0], name)
+	} else {
+		err = fmt.Errorf("%d lights match; rename needs exactly one", len(target.ids))
+	}
+	if err != nil {
+		return err
+	}
+	return sh.refresh()
+}
+
+var (
+	shellCommands = []string{"exit", "groups", "help", "lights", "refresh", "scenes"}
+	shellActions  = []string{"alert", "bri", "color", "ct", "effect", "hue", "off", "on", "rename", "sat", "scene", "show", "transition", "xy"}
+)
+
+// complete is the terminal's key callback: tab completes the word under the
+// cursor, Ctrl-C clears the line.
+func (sh *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key == keyCtrlC {
+		fmt.Fprintln(sh.out, "^C")
+		return "", 0, true
+	}
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	prefix := line[:pos]
+	words, _ := scanWords(prefix)
+	start, partial := len(prefix), ""
+	if len(words) > 0 && words[len(words)-1].end == len(prefix) {
+		last := words[len(words)-1]
+		words = words[:len(words)-1]
+		start, partial = last.start, last.text
+	}
+
+	var candidates []string
+	for _, candidate := range sh.completions(words) {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(partial)) {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	var replacement string
+	switch len(candidates) {
+	case 0:
+		return line, pos, true
+	case 1:
+		replacement = quoteWord(candidates[0]) + " "
+	default:
+		common := commonPrefix(candidates)
+		if len(common) <= len(partial) {
+			fmt.Fprintln(sh.out, strings.Join(candidates, "  "))
+			return line, pos, true
+		}
+		replacement = common
+		if strings.Contains(common, " ") {
+			replacement = `"` + common
+		}
+	}
+
+	newLine := prefix[:start] + replacement + line[pos:]
+	return newLine, start + len(replacement), true
+}
+
+// completions returns the candidates for the word following words.
+func (sh *shell) completions(words []shellWord) []string {
+	if len(words) == 0 {
+		candidates := append([]string{"all"}, shellCommands...)
+		for _, group := range sh.groups {
+			candidates = append(candidates, group.Name)
+		}
+		for _, light := range sh.lights {
+			candidates = append(candidates, light.Name)
+		}
+		return uniqueSorted(candidates)
+	}
+
+	switch strings.ToLower(words[len(words)-1].text) {
+	case "scene":
+		var candidates []string
+		for _, scene := range sh.scenes {
+			candidates = append(candidates, scene.Name)
+		}
+		return uniqueSorted(candidates)
+	case "color":
+		return color.Names()
+	case "alert":
+		return []string{"lselect", "none", "select"}
+	case "effect":
+		return []string{"colorloop", "none"}
+	case "bri", "hue", "sat", "ct", "xy", "transition", "rename":
+		return nil
+	}
+
+	for _, command := range shellCommands {
+		if strings.EqualFold(words[0].text, command) {
+			return nil
+		}
+	}
+	return shellActions
+}
+
+func uniqueSorted(values []string) []string {
+	sort.Strings(values)
+	var result []string
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func commonPrefix(values []string) string {
+	common := values[0]
+	for _, value := range values[1:] {
+		n := 0
+		for n < len(common) && n < len(value) && strings.EqualFold(common[n:n+1], value[n:n+1]) {
+			n++
+		}
+		common = common[:n]
+	}
+	return common
+}
+
+func quoteWord(word string) string {
+	if strings.ContainsAny(word, " \t'\"") {
+		return strconv.Quote(word)
+	}
+	return word
+}
+
+type shellWord struct {
+	text       string
+	start, end int
+}
+
+// scanWords splits a command line on whitespace, honouring single and double
+// quotes. It returns true if the line ends inside an unterminated quote.
+func scanWords(line string) ([]shellWord, bool) {
+	var words []shellWord
+	var current []byte
+	var quote byte
+	inWord := false
+	start := 0
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			current = append(current, c)
+		case c == '"' || c == '\'':
+			if !inWord {
+				inWord, start = true, i
+			}
+			quote = c
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, shellWord{string(current), start, i})
+				current, inWord = nil, false
+			}
+		default:
+			if !inWord {
+				inWord, start = true, i
+			}
+			current = append(current, c)
+		}
+	}
+	if inWord {
+		words = append(words, shellWord{string(current), start, len(line)})
+	}
+	return words, quote != 0
+}
+
+func splitWords(line string) ([]string, error) {
+	words, open := scanWords(line)
+	if open {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.text
+	}
+	return texts, nil
+}
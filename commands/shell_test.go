@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+func TestScanWords(t *testing.T) {
+	tests := []struct {
+		line  string
+		words []shellWord
+		open  bool
+	}{
+		{"", nil, false},
+		{"kitchen on", []shellWord{{"kitchen", 0, 7}, {"on", 8, 10}}, false},
+		{`  "desk lamp"  bri	50`, []shellWord{{"desk lamp", 2, 13}, {"bri", 15, 18}, {"50", 19, 21}}, false},
+		{`'say "hi"'`, []shellWord{{`say "hi"`, 0, 10}}, false},
+		{`""`, []shellWord{{"", 0, 2}}, false},
+		{`desk" "lamp`, []shellWord{{"desk lamp", 0, 11}}, false},
+		{`"desk la`, []shellWord{{"desk la", 0, 8}}, true},
+		{`it's on`, []shellWord{{"its on", 0, 7}}, true},
+	}
+	for _, test := range tests {
+		words, open := scanWords(test.line)
+		assert.Equal(t, test.words, words, test.line)
+		assert.Equal(t, test.open, open, test.line)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	var out bytes.Buffer
+	sh := &shell{
+		out:    &out,
+		lights: []hue.Light{{ID: "1", Name: "Desk lamp"}, {ID: "2", Name: "Desk strip"}, {ID: "3", Name: "Kitchen"}},
+		groups: []hue.Group{{ID: "1", Name: "Office"}},
+		scenes: []hue.Scene{{ID: "a", Name: "Relax"}, {ID: "b", Name: "Read"}},
+	}
+
+	tests := []struct {
+		line, expected string
+		pos, newPos    int
+		listed         string
+	}{
+		{"kit", "Kitchen ", 3, 8, ""},
+		{"kit on", "Kitchen  on", 3, 8, ""},
+		{"De", `"Desk `, 2, 6, ""},
+		{`"Desk l`, `"Desk lamp" `, 7, 12, ""},
+		{"kitchen sc", "kitchen scene ", 10, 14, ""},
+		{"kitchen scene R", "kitchen scene Re", 15, 16, ""},
+		{"kitchen scene Re", "kitchen scene Re", 16, 16, "Read  Relax\n"},
+		{"kitchen color war", "kitchen color warm ", 17, 19, ""},
+		{"kitchen bri ", "kitchen bri ", 12, 12, ""},
+		{"lights x", "lights x", 8, 8, ""},
+		{"o", "Office ", 1, 7, ""},
+		{"office ", "office ", 7, 7, "alert  bri  color  ct  effect  hue  off  on  rename  sat  scene  show  transition  xy\n"},
+	}
+	for _, test := range tests {
+		out.Reset()
+		line, pos, ok := sh.complete(test.line, test.pos, '\t')
+		assert.True(t, ok, test.line)
+		assert.Equal(t, test.expected, line, test.line)
+		assert.Equal(t, test.newPos, pos, test.line)
+		assert.Equal(t, test.listed, out.String(), test.line)
+	}
+
+	out.Reset()
+	line, pos, ok := sh.complete("kitchen on", 10, keyCtrlC)
+	assert.True(t, ok)
+	assert.Equal(t, "", line)
+	assert.Equal(t, 0, pos)
+	assert.Equal(t, "^C\n", out.String())
+
+	_, _, ok = sh.complete("kitchen on", 10, 'x')
+	assert.False(t, ok)
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/hue"
+	"github.com/vincentcr/huecontrol/hue/color"
+)
+
+const colorHelp = `Colors are CSS names such as "orange" or "dark-orange", #rrggbb, rgb(r, g, b),
+hsl(h, s%, l%) or white temperatures: candle, warm, neutral, cool, daylight,
+or kelvins such as 2700K. Brightness is 1-254, a percentage such as 50%, or
+relative to the current brightness, such as +10% or -20.`
+
+// settingFlags are the state flags whose values are parsed by applySetting.
+var settingFlags = []string{"bri", "hue", "sat", "ct", "xy", "color", "alert", "effect"}
+
+func addStateFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.Bool("on", false, "turn on")
+	flags.Bool("off", false, "turn off")
+	flags.String("bri", "", "brightness: 1-254, 50% or relative, e.g. +10%")
+	flags.String("hue", "", "hue: 0-65535 or degrees, e.g. 30deg")
+	flags.String("sat", "", "saturation: 0-254 or 50%")
+	flags.String("ct", "", "color temperature: mireds, kelvin (2700K) or warm, neutral, cool...")
+	flags.String("xy", "", "CIE color coordinates, e.g. 0.3,0.3")
+	flags.String("color", "", "color: name, #rrggbb, rgb(r, g, b), hsl(h, s%, l%) or a temperature")
+	flags.String("alert", "", "none, select or lselect")
+	flags.String("effect", "", "none or colorloop")
+	flags.Uint16("transition", 0, "transition time in tenths of a second")
+}
+
+func stateFromFlags(cmd *cobra.Command) (hue.StateUpdate, error) {
+	flags := cmd.Flags()
+	var state hue.StateUpdate
+
+	if flags.Changed("on") && flags.Changed("off") {
+		return state, fmt.Errorf("--on and --off are mutually exclusive")
+	} else if flags.Changed("on") {
+		state.On = boolPtr(true)
+	} else if flags.Changed("off") {
+		state.On = boolPtr(false)
+	}
+
+	for _, name := range settingFlags {
+		if flags.Changed(name) {
+			value, _ := flags.GetString(name)
+			if err := applySetting(&state, name, value); err != nil {
+				return state, fmt.Errorf("--%v: %v", name, err)
+			}
+		}
+	}
+	if flags.Changed("transition") {
+		transition, _ := flags.GetUint16("transition")
+		state.TransitionTime = &transition
+	}
+	if flags.Lookup("scene") != nil {
+		state.Scene, _ = flags.GetString("scene")
+	}
+
+	return state, nil
+}
+
+// applySetting sets one attribute of state from a human-friendly value, such
+// as "bri" to "+10%" or "color" to "orange". The color temperature is kept
+// within the range most bulbs support; see clampCT for a specific light.
+func applySetting(state *hue.StateUpdate, key string, value string) error {
+	switch key {
+	case "bri":
+		bri, err := color.ParseBrightness(value)
+		if err != nil {
+			return err
+		}
+		if bri.Relative {
+			state.BriInc = &bri.Delta
+		} else {
+			state.Bri = &bri.Value
+		}
+	case "hue":
+		h, err := color.ParseHue(value)
+		if err != nil {
+			return err
+		}
+		state.Hue = &h
+	case "sat":
+		sat, err := color.ParseSaturation(value)
+		if err != nil {
+			return err
+		}
+		state.Sat = &sat
+	case "ct":
+		ct, err := color.ParseTemperature(value)
+		if err != nil {
+			return err
+		}
+		ct = color.ClampCT(ct, 0, 0)
+		state.CT = &ct
+	case "xy":
+		xy, err := parseXY(value)
+		if err != nil {
+			return err
+		}
+		state.XY = xy
+	case "color":
+		c, err := color.Parse(value)
+		if err != nil {
+			return err
+		}
+		applyColor(state, c)
+	case "alert":
+		state.Alert = value
+	case "effect":
+		state.Effect = value
+	default:
+		return unknownSettingError(key)
+	}
+	return nil
+}
+
+type unknownSettingError string
+
+func (e unknownSettingError) Error() string {
+	return fmt.Sprintf("unknown setting %q", string(e))
+}
+
+func applyColor(state *hue.StateUpdate, c color.Color) {
+	if c.Mode == "ct" {
+		ct := color.ClampCT(c.CT, 0, 0)
+		state.CT = &ct
+	} else {
+		state.XY = c.XY
+	}
+}
+
+func parseXY(str string) ([]float32, error) {
+	parts := strings.Split(str, ",")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid xy %q: expected two comma-separated numbers", str)
+	}
+	xy := make([]float32, 2)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil || v < 0 || v > 1 {
+			return nil, fmt.Errorf("invalid xy %q: coordinates must be between 0 and 1", str)
+		}
+		xy[i] = float32(v)
+	}
+	return xy, nil
+}
+
+func parseUint16(value string, max uint16) (uint16, error) {
+	n, err := strconv.ParseUint(value, 10, 16)
+	if err != nil || uint16(n) > max {
+		return 0, fmt.Errorf("invalid value %q: expected a number between 0 and %d", value, max)
+	}
+	return uint16(n), nil
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
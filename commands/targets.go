@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vincentcr/huecontrol/hue"
+	"github.com/vincentcr/huecontrol/hue/color"
+)
+
+// target is a group, or one or more lights.
+type target struct {
+	isGroup bool
+	ids     []string
+}
+
+// resolveTarget prefers groups, so that "kitchen" is the room rather than a
+// light with the same name.
+func resolveTarget(resolver *hue.Resolver, selector string) (target, error) {
+	group, err := resolver.ResolveGroup(selector)
+	if err == nil {
+		return target{isGroup: true, ids: []string{group.ID}}, nil
+	} else if !isNoMatch(err) {
+		return target{}, err
+	}
+
+	lights, err := resolver.ResolveLights(selector)
+	if isNoMatch(err) {
+		return target{}, fmt.Errorf("no light or group matches %q", selector)
+	} else if err != nil {
+		return target{}, err
+	}
+	t := target{}
+	for _, light := range lights {
+		t.ids = append(t.ids, light.ID)
+	}
+	return t, nil
+}
+
+// isNoMatch tells whether err is a selector matching nothing, as opposed to
+// an ambiguous one.
+func isNoMatch(err error) bool {
+	resolveErr, ok := err.(hue.ResolveError)
+	return ok && len(resolveErr.Matches) == 0
+}
+
+func (t target) String() string {
+	if t.isGroup {
+		return "group " + t.ids[0]
+	} else if len(t.ids) == 1 {
+		return "light " + t.ids[0]
+	}
+	return "lights " + strings.Join(t.ids, ",")
+}
+
+func (t target) setState(client *hue.Client, resolver *hue.Resolver, state hue.StateUpdate) error {
+	if t.isGroup {
+		return client.SetGroupState(t.ids[0], state)
+	}
+	for _, id := range t.ids {
+		if err := client.SetLightState(id, clampCT(resolver, id, state)); err != nil {
+			return fmt.Errorf("light %v: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// clampCT keeps the color temperature of state within what the light
+// supports, so that "candle" is as warm as a light can go rather than an error.
+func clampCT(resolver *hue.Resolver, lightID string, state hue.StateUpdate) hue.StateUpdate {
+	if state.CT == nil {
+		return state
+	}
+	light, err := resolver.ResolveLight(lightID)
+	if err != nil {
+		return state
+	}
+	ct := color.ClampCT(*state.CT, light.Capabilities.Control.CT.Min, light.Capabilities.Control.CT.Max)
+	state.CT = &ct
+	return state
+}
+
+// lightIDs returns the IDs of the lights the target changes.
+func (t target) lightIDs(resolver *hue.Resolver) []string {
+	if !t.isGroup {
+		return t.ids
+	}
+	group, err := resolver.ResolveGroup(t.ids[0])
+	if err != nil {
+		return nil
+	}
+	return group.Lights
+}
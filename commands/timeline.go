@@ -0,0 +1,426 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vincentcr/huecontrol/hue"
+	"github.com/vincentcr/huecontrol/hue/color"
+)
+
+const timelineHelp = `A timeline has one step per line:
+
+  <time> <target> <action>...
+  <time> fade <target> to <action>... over <duration>
+
+Times are offsets from the start, such as 0s, 1m30s or 1:30, or from the
+previous step when prefixed with "+", such as +5s. Actions are on, off,
+colors and bri=, ct=, hue=, sat=, xy=, color=, scene=, alert=, effect= and
+transition= settings.
+
+` + colorHelp + `
+
+Other lines are:
+
+  # a comment
+  set <name> = <value>   define a variable, used as $name or ${name}
+  loop [N]               repeat the steps since the previous loop N times,
+                         or forever
+
+Example:
+
+  set room = living
+  0s   kitchen on bri=80%
+  +5s  fade $room to orange bri=+20% over 3s
+  +5s  "desk lamp" off
+  loop 3`
+
+// timelineStep is a state change sent to a target at a given time.
+type timelineStep struct {
+	line     int
+	text     string
+	at       time.Duration
+	selector string
+	scene    string
+	state    hue.StateUpdate
+	target   target
+}
+
+// end is when the step's transition, if any, is over.
+func (step timelineStep) end() time.Duration {
+	if step.state.TransitionTime == nil {
+		return step.at
+	}
+	return step.at + time.Duration(*step.state.TransitionTime)*100*time.Millisecond
+}
+
+// timelineBlock is a sequence of steps run one or more times. Step times are
+// relative to the start of each run.
+type timelineBlock struct {
+	steps []timelineStep
+	// count is the number of runs; 0 is forever.
+	count int
+}
+
+func (block timelineBlock) duration() time.Duration {
+	var duration time.Duration
+	for _, step := range block.steps {
+		if step.end() > duration {
+			duration = step.end()
+		}
+	}
+	return duration
+}
+
+type timeline struct {
+	blocks []timelineBlock
+}
+
+// parseTimeline reads a timeline. vars are defined before the file is read
+// and take precedence over the file's own definitions.
+func parseTimeline(r io.Reader, vars map[string]string) (*timeline, error) {
+	defined := make(map[string]string)
+	for name, value := range vars {
+		defined[name] = value
+	}
+
+	tl := &timeline{}
+	var block timelineBlock
+	var previous time.Duration
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		words, err := splitWords(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		if err := expandVariables(words, defined); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+
+		switch strings.ToLower(words[0]) {
+		case "set":
+			name, value, err := parseTimelineVariable(words[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			if _, found := vars[name]; !found {
+				defined[name] = value
+			}
+		case "loop":
+			if len(block.steps) == 0 {
+				return nil, fmt.Errorf("line %d: loop has no steps to repeat", lineNum)
+			}
+			if block.count, err = parseLoopCount(words); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			if block.count == 0 && block.duration() == 0 {
+				return nil, fmt.Errorf("line %d: a loop that runs forever needs steps spread over time", lineNum)
+			}
+			tl.blocks = append(tl.blocks, block)
+			block, previous = timelineBlock{}, 0
+		default:
+			step, err := parseTimelineStep(words, previous)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			step.line, step.text = lineNum, text
+			block.steps = append(block.steps, step)
+			previous = step.at
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read timeline: %v", err)
+	}
+
+	if len(block.steps) > 0 {
+		block.count = 1
+		tl.blocks = append(tl.blocks, block)
+	}
+	if len(tl.blocks) == 0 {
+		return nil, fmt.Errorf("timeline has no steps")
+	}
+	return tl, nil
+}
+
+func expandVariables(words []string, vars map[string]string) error {
+	var undefined []string
+	for i, word := range words {
+		words[i] = os.Expand(word, func(name string) string {
+			value, found := vars[name]
+			if !found {
+				undefined = append(undefined, name)
+			}
+			return value
+		})
+	}
+	if len(undefined) > 0 {
+		return fmt.Errorf("undefined variable %v", strings.Join(undefined, ", "))
+	}
+	return nil
+}
+
+// parseTimelineVariable parses the words following set: "name = value" or "name=value".
+func parseTimelineVariable(words []string) (string, string, error) {
+	parts := strings.SplitN(strings.Join(words, " "), "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return "", "", fmt.Errorf("expected set <name> = <value>")
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
+}
+
+func parseLoopCount(words []string) (int, error) {
+	switch len(words) {
+	case 1:
+		return 0, nil
+	case 2:
+		count, err := strconv.Atoi(words[1])
+		if err != nil || count < 1 {
+			return 0, fmt.Errorf("invalid loop count %q", words[1])
+		}
+		return count, nil
+	default:
+		return 0, fmt.Errorf("expected loop [count]")
+	}
+}
+
+func parseTimelineStep(words []string, previous time.Duration) (timelineStep, error) {
+	var step timelineStep
+	if len(words) < 3 {
+		return step, fmt.Errorf("expected <time> <target> <action>...")
+	}
+
+	at, err := parseTimelineTime(words[0], previous)
+	if err != nil {
+		return step, err
+	}
+	if at < previous {
+		return step, fmt.Errorf("%v is before the previous step", words[0])
+	}
+	step.at = at
+
+	if !strings.EqualFold(words[1], "fade") {
+		step.selector = words[1]
+		return step, parseTimelineActions(words[2:], &step)
+	}
+
+	// fade <target> to <action>... over <duration>
+	if len(words) < 7 || !strings.EqualFold(words[3], "to") || !strings.EqualFold(words[len(words)-2], "over") {
+		return step, fmt.Errorf("expected <time> fade <target> to <action>... over <duration>")
+	}
+	step.selector = words[2]
+	if err := parseTimelineActions(words[4:len(words)-2], &step); err != nil {
+		return step, err
+	}
+	transition, err := parseTransition(words[len(words)-1])
+	if err != nil {
+		return step, err
+	}
+	step.state.TransitionTime = &transition
+	return step, nil
+}
+
+func parseTimelineTime(word string, previous time.Duration) (time.Duration, error) {
+	relative := strings.HasPrefix(word, "+")
+	d, err := parseTimelineDuration(strings.TrimPrefix(word, "+"))
+	if err != nil {
+		return 0, err
+	}
+	if relative {
+		return previous + d, nil
+	}
+	return d, nil
+}
+
+// parseTimelineDuration accepts Go durations such as 1m30s, and clock
+// durations such as 1:30 or 1:00:00.
+func parseTimelineDuration(s string) (time.Duration, error) {
+	if !strings.Contains(s, ":") {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		return d, nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	var d time.Duration
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		d = d*60 + time.Duration(n*float64(time.Second))
+	}
+	return d, nil
+}
+
+// parseTransition converts a duration to the bridge's tenths of a second.
+func parseTransition(s string) (uint16, error) {
+	d, err := parseTimelineDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	tenths := d / (100 * time.Millisecond)
+	if tenths > 65535 {
+		return 0, fmt.Errorf("transition %v is too long", s)
+	}
+	return uint16(tenths), nil
+}
+
+func parseTimelineActions(words []string, step *timelineStep) error {
+	if len(words) == 0 {
+		return fmt.Errorf("missing action")
+	}
+	state := &step.state
+	for _, word := range words {
+		lower := strings.ToLower(word)
+		if lower == "on" || lower == "off" {
+			state.On = boolPtr(lower == "on")
+			continue
+		}
+
+		parts := strings.SplitN(word, "=", 2)
+		if len(parts) != 2 {
+			// a bare color, such as "#ff8800", "red" or "warm"
+			c, err := color.Parse(word)
+			if err != nil {
+				return fmt.Errorf("unknown action %q", word)
+			}
+			applyColor(state, c)
+			continue
+		}
+		key, value := strings.ToLower(parts[0]), parts[1]
+		var err error
+		switch key {
+		case "transition":
+			var transition uint16
+			transition, err = parseTransition(value)
+			state.TransitionTime = &transition
+		case "scene":
+			step.scene = value
+		default:
+			err = applySetting(state, key, value)
+			if _, known := err.(unknownSettingError); known {
+				return fmt.Errorf("unknown action %q", word)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// bind resolves the targets and scenes of all steps against the bridge.
+func (tl *timeline) bind(client *hue.Client) (*hue.Resolver, error) {
+	resolver, err := client.NewResolver()
+	if err != nil {
+		return nil, err
+	}
+	if resolver.Scenes, err = client.GetScenes(); err != nil {
+		return nil, err
+	}
+
+	for i := range tl.blocks {
+		steps := tl.blocks[i].steps
+		for j := range steps {
+			step := &steps[j]
+			if step.target, err = resolveTarget(resolver, step.selector); err != nil {
+				return nil, fmt.Errorf("line %d: %v", step.line, err)
+			}
+			if step.scene == "" {
+				continue
+			}
+			if !step.target.isGroup {
+				return nil, fmt.Errorf("line %d: scenes can only be recalled on groups", step.line)
+			}
+			scene, err := resolver.ResolveScene(step.scene)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", step.line, err)
+			}
+			step.state.Scene = scene.ID
+		}
+	}
+	return resolver, nil
+}
+
+// lightIDs returns the lights changed by the timeline, for restoring them.
+func (tl *timeline) lightIDs(resolver *hue.Resolver) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, block := range tl.blocks {
+		for _, step := range block.steps {
+			for _, id := range step.target.lightIDs(resolver) {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// describe writes what the timeline would do, for dry runs.
+func (tl *timeline) describe(out io.Writer) {
+	for i, block := range tl.blocks {
+		runs := "forever"
+		if block.count == 1 {
+			runs = "once"
+		} else if block.count > 1 {
+			runs = fmt.Sprintf("%d times", block.count)
+		}
+		fmt.Fprintf(out, "block %d: %v steps over %v, run %v\n", i+1, len(block.steps), block.duration(), runs)
+		for _, step := range block.steps {
+			state, _ := json.Marshal(step.state)
+			fmt.Fprintf(out, "  %7.1fs  line %-3d %v -> %v %s\n", step.at.Seconds(), step.line, step.text, step.target, state)
+		}
+	}
+}
+
+// run executes the timeline until it is over or stop is closed. It returns
+// false if it was stopped.
+func (tl *timeline) run(client *hue.Client, resolver *hue.Resolver, out io.Writer, stop <-chan struct{}) bool {
+	started := time.Now()
+	for _, block := range tl.blocks {
+		for run := 0; block.count == 0 || run < block.count; run++ {
+			start := time.Now()
+			for _, step := range block.steps {
+				if !sleepUntil(start.Add(step.at), stop) {
+					return false
+				}
+				fmt.Fprintf(out, "%7.1fs  line %-3d %v\n", time.Since(started).Seconds(), step.line, step.text)
+				if err := step.target.setState(client, resolver, step.state); err != nil {
+					fmt.Fprintf(out, "          line %-3d error: %v\n", step.line, err)
+				}
+			}
+			if !sleepUntil(start.Add(block.duration()), stop) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sleepUntil(t time.Time, stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return false
+	case <-time.After(t.Sub(time.Now())):
+		return true
+	}
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeline(t *testing.T) {
+	text := `
+# wake up
+set room = living
+set level = 50%
+0s     kitchen on bri=$level
++5s    fade ${room} to #ff0000 over 3s
+1:00   "desk lamp" off
+loop 3
+10s    all off
+`
+	tl, err := parseTimeline(strings.NewReader(text), map[string]string{"room": "bedroom"})
+	assert.Nil(t, err)
+	assert.Len(t, tl.blocks, 2)
+
+	block := tl.blocks[0]
+	assert.Equal(t, 3, block.count)
+	assert.Equal(t, time.Minute, block.duration())
+	assert.Len(t, block.steps, 3)
+
+	step := block.steps[0]
+	assert.Equal(t, time.Duration(0), step.at)
+	assert.Equal(t, "kitchen", step.selector)
+	assert.True(t, *step.state.On)
+	assert.Equal(t, uint8(127), *step.state.Bri)
+
+	step = block.steps[1]
+	assert.Equal(t, 5*time.Second, step.at)
+	assert.Equal(t, "bedroom", step.selector)
+	assert.Equal(t, uint16(30), *step.state.TransitionTime)
+	assert.InDelta(t, 0.7006, step.state.XY[0], 0.001)
+	assert.InDelta(t, 0.2993, step.state.XY[1], 0.001)
+
+	step = block.steps[2]
+	assert.Equal(t, time.Minute, step.at)
+	assert.Equal(t, "desk lamp", step.selector)
+	assert.False(t, *step.state.On)
+
+	assert.Equal(t, 1, tl.blocks[1].count)
+	assert.Equal(t, 10*time.Second, tl.blocks[1].steps[0].at)
+}
+
+func TestParseTimelineErrors(t *testing.T) {
+	tests := map[string]string{
+		"0s kitchen on\n5s kitchen off\n1s kitchen on": "line 3: 1s is before the previous step",
+		"0s $nope on":                     "line 1: undefined variable nope",
+		"0s kitchen dance":                `line 1: unknown action "dance"`,
+		"0s fade kitchen #ff0000 over 3s": "line 1: expected <time> fade <target> to <action>... over <duration>",
+		"0s kitchen on\nloop":             "line 2: a loop that runs forever needs steps spread over time",
+		"loop 2":                          "line 1: loop has no steps to repeat",
+		"0s kitchen bri=300":              `line 1: bri: invalid brightness "300": expected 1 to 254, or a percentage`,
+		"# nothing to do":                 "timeline has no steps",
+	}
+	for text, expected := range tests {
+		_, err := parseTimeline(strings.NewReader(text), nil)
+		if assert.NotNil(t, err, text) {
+			assert.Equal(t, expected, err.Error(), text)
+		}
+	}
+}
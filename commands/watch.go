@@ -0,0 +1,369 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vincentcr/huecontrol/hue"
+)
+
+const (
+	watchAdded   = "added"
+	watchChanged = "changed"
+	watchRemoved = "removed"
+
+	clearScreen = "\x1b[H\x1b[2J"
+)
+
+var watchKinds = []string{"light", "group", "sensor"}
+
+var watchCommand = &cobra.Command{
+	Use:   "watch [target]",
+	Short: "Show light, group and sensor changes as they happen",
+	Long: `Poll the bridge and show changes to lights, groups and sensors.
+
+With the default table output, the screen is refreshed with the current state
+of each object and the time it last changed. With -o ndjson, one JSON event is
+written per change instead, e.g.
+
+  {"time":"2015-10-10T06:00:00Z","type":"changed","kind":"light","id":"14",
+   "name":"Desk lamp","changes":{"state.on":{"old":false,"new":true}}}
+
+The optional target restricts the objects watched. ` + targetHelp,
+	Run: checkedRun(func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return fmt.Errorf("usage: %v", cmd.UseLine())
+		}
+		selector := ""
+		if len(args) == 1 {
+			selector = args[0]
+		}
+
+		interval, _ := cmd.Flags().GetDuration("interval")
+		kinds, _ := cmd.Flags().GetStringSlice("kind")
+		for _, kind := range kinds {
+			if !containsString(watchKinds, kind) {
+				return fmt.Errorf("unknown kind %q; expected %v", kind, strings.Join(watchKinds, ", "))
+			}
+		}
+
+		format := "table"
+		if cmd.Flag("output").Changed {
+			format = outputFlag
+		}
+		if format != "table" && format != "ndjson" {
+			return fmt.Errorf("watch supports table and ndjson output")
+		}
+
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		w := &watcher{client: client, kinds: kinds, selector: selector, lastChanges: make(map[string]watchEvent)}
+		if format == "ndjson" {
+			return w.streamEvents(os.Stdout, interval)
+		}
+		// request logs would scroll the table off the screen.
+		log.SetOutput(ioutil.Discard)
+		return w.showTable(os.Stdout, interval)
+	}),
+}
+
+func init() {
+	flags := watchCommand.Flags()
+	flags.Duration("interval", time.Second, "how often to poll the bridge")
+	flags.StringSlice("kind", watchKinds, "kinds of objects to watch: light, group and/or sensor")
+}
+
+// watchEvent is an object appearing, disappearing or changing between two polls.
+type watchEvent struct {
+	Time    time.Time              `json:"time"`
+	Type    string                 `json:"type"`
+	Kind    string                 `json:"kind"`
+	ID      string                 `json:"id"`
+	Name    string                 `json:"name"`
+	Changes map[string]watchChange `json:"changes,omitempty"`
+}
+
+type watchChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// watchedObject is a light, group or sensor, with its state flattened to
+// attributes such as "state.bri" so that it can be compared between polls.
+type watchedObject struct {
+	kind       string
+	id         string
+	name       string
+	summary    string
+	attributes map[string]interface{}
+}
+
+func (obj watchedObject) key() string {
+	return obj.kind + "/" + obj.id
+}
+
+// watchSnapshot is the state of all watched objects, in display order.
+type watchSnapshot struct {
+	objects []watchedObject
+	byKey   map[string]watchedObject
+}
+
+func (s *watchSnapshot) add(obj watchedObject) {
+	s.objects = append(s.objects, obj)
+	s.byKey[obj.key()] = obj
+}
+
+type watcher struct {
+	client      *hue.Client
+	kinds       []string
+	selector    string
+	previous    *watchSnapshot
+	lastChanges map[string]watchEvent
+}
+
+func (w *watcher) streamEvents(out io.Writer, interval time.Duration) error {
+	encoder := json.NewEncoder(out)
+	for {
+		events, err := w.poll()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", time.Now().Format(time.RFC3339), err)
+		}
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return fmt.Errorf("unable to write event: %v", err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+func (w *watcher) showTable(out io.Writer, interval time.Duration) error {
+	for {
+		_, err := w.poll()
+
+		status := "updated " + time.Now().Format("15:04:05")
+		if err != nil {
+			status = fmt.Sprintf("%v: %v", time.Now().Format("15:04:05"), err)
+		}
+		fmt.Fprintf(out, "%vWatching %v every %v; %v\n\n", clearScreen, w.client.Hostname, interval, status)
+
+		if w.previous != nil {
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "KIND\tID\tNAME\tSTATE\tCHANGED\tFIELDS")
+			for _, obj := range w.previous.objects {
+				changed, fields := "-", ""
+				if event, found := w.lastChanges[obj.key()]; found {
+					changed = event.Time.Format("15:04:05")
+					fields = strings.Join(changedFields(event), ",")
+				}
+				fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", obj.kind, obj.id, obj.name, obj.summary, changed, fields)
+			}
+			tw.Flush()
+		}
+		time.Sleep(interval)
+	}
+}
+
+// poll fetches the current state and returns the changes since the previous
+// poll. The first poll only records the initial state.
+func (w *watcher) poll() ([]watchEvent, error) {
+	current, err := w.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []watchEvent
+	if w.previous != nil {
+		events = diffSnapshots(w.previous, current, time.Now())
+	}
+	for _, event := range events {
+		w.lastChanges[event.Kind+"/"+event.ID] = event
+	}
+	w.previous = current
+	return events, nil
+}
+
+func (w *watcher) fetch() (*watchSnapshot, error) {
+	resolver := &hue.Resolver{}
+	var err error
+	if containsString(w.kinds, "light") || w.selector != "" {
+		if resolver.Lights, err = w.client.GetLights(); err != nil {
+			return nil, err
+		}
+	}
+	if containsString(w.kinds, "group") || w.selector != "" {
+		if resolver.Groups, err = w.client.GetGroups(); err != nil {
+			return nil, err
+		}
+	}
+	if containsString(w.kinds, "sensor") {
+		if resolver.Sensors, err = w.client.GetSensors(); err != nil {
+			return nil, err
+		}
+	}
+
+	lights, groups, sensors := resolver.Lights, resolver.Groups, resolver.Sensors
+	if w.selector != "" {
+		if lights, err = resolver.ResolveLights(w.selector); isNoMatch(err) {
+			lights = nil
+		} else if err != nil {
+			return nil, err
+		}
+		if groups, err = resolver.ResolveGroups(w.selector); isNoMatch(err) {
+			groups = nil
+		} else if err != nil {
+			return nil, err
+		}
+		if sensors, err = resolver.ResolveSensors(w.selector); isNoMatch(err) {
+			sensors = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	snapshot := &watchSnapshot{byKey: make(map[string]watchedObject)}
+	if containsString(w.kinds, "light") {
+		for _, light := range lights {
+			state := light.State
+			summary := fmt.Sprintf("%v bri %d %v", onOff(state.On), state.Bri,
+				colorDescription(state.ColorMode, state.Hue, state.Sat, state.XY, state.CT))
+			if !state.Reachable {
+				summary += " unreachable"
+			}
+			snapshot.add(newWatchedObject("light", light.ID, light.Name, summary, map[string]interface{}{"state": state}))
+		}
+	}
+	if containsString(w.kinds, "group") {
+		for _, group := range groups {
+			summary := "off"
+			if group.State != nil && group.State.AllOn {
+				summary = "all on"
+			} else if group.State != nil && group.State.AnyOn {
+				summary = "some on"
+			}
+			attributes := map[string]interface{}{"action": group.Action, "state": group.State, "lights": group.Lights}
+			snapshot.add(newWatchedObject("group", group.ID, group.Name, summary, attributes))
+		}
+	}
+	if containsString(w.kinds, "sensor") {
+		for _, sensor := range sensors {
+			attributes := map[string]interface{}{"state": sensor.State, "config": sensor.Config}
+			snapshot.add(newWatchedObject("sensor", sensor.ID, sensor.Name, sensorSummary(sensor), attributes))
+		}
+	}
+	if w.selector != "" && len(snapshot.objects) == 0 {
+		kinds := strings.Join(w.kinds, ", ")
+		if i := strings.LastIndex(kinds, ", "); i >= 0 {
+			kinds = kinds[:i] + " or " + kinds[i+2:]
+		}
+		return nil, hue.ResolveError{Selector: w.selector, Kind: kinds}
+	}
+	return snapshot, nil
+}
+
+func newWatchedObject(kind, id, name, summary string, attributes map[string]interface{}) watchedObject {
+	attributes["name"] = name
+	flattened := make(map[string]interface{})
+	flattenAttributes("", normalizeAttributes(attributes), flattened)
+	return watchedObject{kind: kind, id: id, name: name, summary: summary, attributes: flattened}
+}
+
+// normalizeAttributes round-trips attributes through JSON, so that values read
+// from different structs compare equal.
+func normalizeAttributes(attributes map[string]interface{}) map[string]interface{} {
+	encoded, _ := json.Marshal(attributes)
+	var normalized map[string]interface{}
+	json.Unmarshal(encoded, &normalized)
+	return normalized
+}
+
+func flattenAttributes(prefix string, value interface{}, flattened map[string]interface{}) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		flattened[prefix] = value
+		return
+	}
+	for key, v := range m {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flattenAttributes(strings.ToLower(key), v, flattened)
+	}
+}
+
+func sensorSummary(sensor hue.Sensor) string {
+	var parts []string
+	for key, value := range sensor.State {
+		if key != "lastupdated" {
+			parts = append(parts, fmt.Sprintf("%v=%v", key, value))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func diffSnapshots(previous, current *watchSnapshot, now time.Time) []watchEvent {
+	var events []watchEvent
+	for _, obj := range current.objects {
+		old, found := previous.byKey[obj.key()]
+		if !found {
+			events = append(events, watchEvent{Time: now, Type: watchAdded, Kind: obj.kind, ID: obj.id, Name: obj.name})
+			continue
+		}
+
+		changes := make(map[string]watchChange)
+		for key, value := range obj.attributes {
+			if oldValue := old.attributes[key]; !reflect.DeepEqual(oldValue, value) {
+				changes[key] = watchChange{Old: oldValue, New: value}
+			}
+		}
+		for key, oldValue := range old.attributes {
+			if _, found := obj.attributes[key]; !found {
+				changes[key] = watchChange{Old: oldValue}
+			}
+		}
+		if len(changes) > 0 {
+			events = append(events, watchEvent{Time: now, Type: watchChanged, Kind: obj.kind, ID: obj.id, Name: obj.name, Changes: changes})
+		}
+	}
+	for _, obj := range previous.objects {
+		if _, found := current.byKey[obj.key()]; !found {
+			events = append(events, watchEvent{Time: now, Type: watchRemoved, Kind: obj.kind, ID: obj.id, Name: obj.name})
+		}
+	}
+	return events
+}
+
+// changedFields lists what an event changed, for display.
+func changedFields(event watchEvent) []string {
+	if event.Type != watchChanged {
+		return []string{event.Type}
+	}
+	var fields []string
+	for key := range event.Changes {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
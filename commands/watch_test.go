@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlattenAttributes(t *testing.T) {
+	flattened := make(map[string]interface{})
+	flattenAttributes("", map[string]interface{}{
+		"name": "Desk",
+		"state": map[string]interface{}{
+			"on":  true,
+			"xy":  []interface{}{0.3, 0.4},
+			"Bri": 200.0,
+		},
+		"config": map[string]interface{}{},
+	}, flattened)
+
+	assert.Equal(t, map[string]interface{}{
+		"name":      "Desk",
+		"state.on":  true,
+		"state.xy":  []interface{}{0.3, 0.4},
+		"state.bri": 200.0,
+	}, flattened)
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	snapshot := func(objects ...watchedObject) *watchSnapshot {
+		s := &watchSnapshot{byKey: make(map[string]watchedObject)}
+		for _, obj := range objects {
+			s.add(obj)
+		}
+		return s
+	}
+	now := time.Now()
+
+	desk := newWatchedObject("light", "1", "Desk", "", map[string]interface{}{"state": map[string]interface{}{"on": false, "bri": 100}})
+	deskOn := newWatchedObject("light", "1", "Desk", "", map[string]interface{}{"state": map[string]interface{}{"on": true, "bri": 100, "alert": "none"}})
+	lamp := newWatchedObject("light", "2", "Lamp", "", map[string]interface{}{})
+	office := newWatchedObject("group", "2", "Office", "", map[string]interface{}{"lights": []string{"1"}})
+	officeLess := newWatchedObject("group", "2", "Office", "", map[string]interface{}{})
+
+	assert.Empty(t, diffSnapshots(snapshot(desk, office), snapshot(desk, office), now))
+
+	events := diffSnapshots(snapshot(desk, lamp, office), snapshot(deskOn, officeLess), now)
+	assert.Equal(t, []watchEvent{
+		{Time: now, Type: watchChanged, Kind: "light", ID: "1", Name: "Desk", Changes: map[string]watchChange{
+			"state.on":    {Old: false, New: true},
+			"state.alert": {New: "none"},
+		}},
+		{Time: now, Type: watchChanged, Kind: "group", ID: "2", Name: "Office", Changes: map[string]watchChange{
+			"lights": {Old: []interface{}{"1"}},
+		}},
+		{Time: now, Type: watchRemoved, Kind: "light", ID: "2", Name: "Lamp"},
+	}, events)
+
+	events = diffSnapshots(snapshot(), snapshot(lamp), now)
+	assert.Equal(t, []watchEvent{{Time: now, Type: watchAdded, Kind: "light", ID: "2", Name: "Lamp"}}, events)
+}
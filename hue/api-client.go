@@ -2,12 +2,18 @@ package hue
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vincentcr/huecontrol/telemetry"
 )
 
 const meethueURL = "https://www.meethue.com"
@@ -23,6 +29,7 @@ type Client struct {
 	Username string
 
 	client *http.Client
+	ctx    context.Context
 }
 
 func New(hostname string, username string) *Client {
@@ -32,9 +39,35 @@ func New(hostname string, username string) *Client {
 		rootURL:  rootURL,
 		Username: username,
 		Hostname: hostname,
+		ctx:      context.Background(),
 	}
 }
 
+// WithContext returns a copy of the client whose calls to the bridge are
+// traced as children of the span in ctx.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+// NewWithTransport creates a client whose requests to the bridge go through
+// transport, such as one relaying them through a remote service.
+func NewWithTransport(hostname string, username string, transport http.RoundTripper) *Client {
+	c := New(hostname, username)
+	c.client.Transport = transport
+	return c
+}
+
+// NewWithTLS creates a client that talks to the bridge over https, e.g. with
+// the configuration returned by CertificatePin.TLSConfig.
+func NewWithTLS(hostname string, username string, config *tls.Config) *Client {
+	c := New(hostname, username)
+	c.rootURL = fmt.Sprintf("https://%v/api/%v", hostname, username)
+	c.client.Transport = &http.Transport{TLSClientConfig: config}
+	return c
+}
+
 func (c *Client) get(path string, resObject interface{}) error {
 	return c.do("GET", path, nil, resObject)
 }
@@ -51,12 +84,65 @@ func (c *Client) delete(path string, reqObject interface{}, resObject interface{
 	return c.do("DELETE", path, reqObject, resObject)
 }
 
+// create posts a new resource to the given collection and returns the ID assigned by the bridge.
+func (c *Client) create(path string, reqObject interface{}) (string, error) {
+	var results []apiResult
+	if err := c.post(path, reqObject, &results); err != nil {
+		return "", err
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			return "", *result.Error
+		}
+		if id, ok := result.Success["id"].(string); ok {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("hue.Client POST %v: no id in response", c.url(path))
+}
+
+// update sends a PUT and returns the first error reported by the bridge in the response.
+func (c *Client) update(path string, reqObject interface{}) error {
+	var results []apiResult
+	if err := c.put(path, reqObject, &results); err != nil {
+		return err
+	}
+	return firstError(results)
+}
+
+func (c *Client) remove(path string) error {
+	var results []apiResult
+	if err := c.delete(path, nil, &results); err != nil {
+		return err
+	}
+	return firstError(results)
+}
+
+func firstError(results []apiResult) error {
+	for _, result := range results {
+		if result.Error != nil {
+			return *result.Error
+		}
+	}
+	return nil
+}
+
 func (c *Client) do(method string, path string, reqObject interface{}, resObject interface{}) error {
-	return do(c.client, method, c.url(path), reqObject, resObject)
+	return do(c.ctx, c.client, method, c.url(path), reqObject, resObject)
 }
 
-func do(client *http.Client, method string, url string, reqObject interface{}, resObject interface{}) error {
+func do(ctx context.Context, client *http.Client, method string, url string, reqObject interface{}, resObject interface{}) error {
+	ctx, span := telemetry.StartSpan(ctx, "hue "+method)
+	start := time.Now()
+	status := "ok"
+	defer func() {
+		span.SetAttribute("status", status)
+		span.Finish()
+		observeRequest(url, method, status, time.Since(start))
+	}()
+
 	error := func(msg string, err error) error {
+		status = msg
 		return fmt.Errorf("hue.Client %v %v: %v: %v", method, url, msg, err)
 	}
 	var reqBody io.Reader = nil
@@ -73,9 +159,18 @@ func do(client *http.Client, method string, url string, reqObject interface{}, r
 	if err != nil {
 		return error("request", err)
 	}
+	req = req.WithContext(ctx)
 	req.Header.Add("content-type", "application/json")
+	telemetry.InjectHeader(ctx, req.Header)
+	span.SetAttribute("endpoint", endpointLabel(req.URL.Path))
+	span.SetAttribute("bridge", req.URL.Host)
+
 	res, err := client.Do(req)
+	if err != nil {
+		return error("request", err)
+	}
 	defer res.Body.Close()
+	status = strconv.Itoa(res.StatusCode)
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -89,6 +184,12 @@ func do(client *http.Client, method string, url string, reqObject interface{}, r
 	if resObject != nil {
 		err = json.Unmarshal(body, resObject)
 		if err != nil {
+			// reads report errors, such as an unknown light, as a list of results
+			var results []apiResult
+			if json.Unmarshal(body, &results) == nil && firstError(results) != nil {
+				status = "error"
+				return firstError(results)
+			}
 			return error("decoding", fmt.Errorf("%v: %v", string(body), err))
 		}
 	}
@@ -1,50 +1,100 @@
 package hue
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"sort"
 )
 
 const (
-	ErrCodesLinkButtonNotPressed = 101
+	ErrCodesUnauthorizedUser       = 1
+	ErrCodesInvalidJSON            = 2
+	ErrCodesResourceNotAvailable   = 3
+	ErrCodesMethodNotAvailable     = 4
+	ErrCodesMissingParameters      = 5
+	ErrCodesParameterNotAvailable  = 6
+	ErrCodesInvalidValue           = 7
+	ErrCodesParameterNotModifiable = 8
+	ErrCodesLinkButtonNotPressed   = 101
+	ErrCodesDeviceIsOff            = 201
+	ErrCodesInternalError          = 901
 )
 
 var (
 	ErrLinkButtonNotPressed = fmt.Errorf("link button not pressed")
 )
 
+// Error is an error reported by the bridge in the body of an otherwise successful response.
+type Error struct {
+	Type        int
+	Address     string
+	Description string
+}
+
+func (err Error) Error() string {
+	return fmt.Sprintf("bridge error: type: %v; address: %v; description: %v", err.Type, err.Address, err.Description)
+}
+
+type apiResult struct {
+	Success map[string]interface{}
+	Error   *Error
+}
+
 func DiscoverBridges() ([]BridgeInfo, error) {
 	var bridges []BridgeInfo
 	url := fmt.Sprintf("%v/api/nupnp", meethueURL)
-	err := do(http.DefaultClient, "GET", url, nil, &bridges)
+	err := do(context.Background(), http.DefaultClient, "GET", url, nil, &bridges)
 	if err != nil {
 		return nil, fmt.Errorf("DiscoverBridges: %v", err)
 	}
 	return bridges, nil
 }
 
-func RegisterUser(hostname string) (string, error) {
-	var result struct {
+// RegisterUser creates a whitelist user on the bridge. It returns
+// ErrLinkButtonNotPressed until the link button of the bridge has been pressed.
+// deviceType identifies the application, e.g. "huecontrol#laptop".
+func RegisterUser(hostname string, deviceType string) (string, error) {
+	var results []struct {
 		Success struct {
 			Username string
 		}
-		Error struct {
-			Description string
-			Type        int
-		}
+		Error *Error
 	}
 	url := fmt.Sprintf("http://%v/api", hostname)
-	err := do(http.DefaultClient, "GET", url, nil, &result)
+	req := map[string]string{"devicetype": deviceType}
+	err := do(context.Background(), http.DefaultClient, "POST", url, req, &results)
 
 	if err != nil {
 		return "", fmt.Errorf("RegisterUser: %v", err)
-	} else if result.Error.Type == ErrCodesLinkButtonNotPressed {
+	} else if len(results) == 0 {
+		return "", fmt.Errorf("RegisterUser: empty response")
+	} else if results[0].Error != nil && results[0].Error.Type == ErrCodesLinkButtonNotPressed {
 		return "", ErrLinkButtonNotPressed
-	} else if result.Error.Description != "" {
-		return "", fmt.Errorf("RegisterUser: error: type: %v; description: %v", result.Error.Type, result.Error.Description)
+	} else if results[0].Error != nil {
+		return "", fmt.Errorf("RegisterUser: %v", *results[0].Error)
 	} else {
-		return result.Success.Username, nil
+		return results[0].Success.Username, nil
+	}
+}
+
+// PublicConfig is the part of the bridge configuration readable without a user.
+type PublicConfig struct {
+	Name       string
+	BridgeID   string `json:"bridgeid"`
+	APIVersion string `json:"apiversion"`
+	SWVersion  string `json:"swversion"`
+	MAC        string
+	ModelID    string `json:"modelid"`
+}
+
+func GetPublicConfig(hostname string) (PublicConfig, error) {
+	var config PublicConfig
+	url := fmt.Sprintf("http://%v/api/config", hostname)
+	if err := do(context.Background(), http.DefaultClient, "GET", url, nil, &config); err != nil {
+		return PublicConfig{}, fmt.Errorf("GetPublicConfig: %v", err)
 	}
+	return config, nil
 }
 
 func (c *Client) GetLights() ([]Light, error) {
@@ -58,6 +108,7 @@ func (c *Client) GetLights() ([]Light, error) {
 		light.ID = id
 		lights = append(lights, light)
 	}
+	sort.Sort(lightsByID(lights))
 	return lights, nil
 }
 
@@ -86,6 +137,7 @@ func (c *Client) GetGroups() ([]Group, error) {
 		group.ID = id
 		groups = append(groups, group)
 	}
+	sort.Sort(groupsByID(groups))
 	return groups, nil
 }
 
@@ -95,10 +147,148 @@ func (c *Client) GetGroup(id string) (Group, error) {
 	return group, err
 }
 
+// UpdateGroup writes the group's attributes. Its state is read only, so it is
+// left out.
 func (c *Client) UpdateGroup(group Group) error {
+	group.State = nil
 	return c.put("/groups/"+group.ID, group, nil)
 }
 
 func (c *Client) UpdateGroupState(group Group) error {
 	return c.put("/groups/"+group.ID+"/action", group.Action, nil)
 }
+
+func (c *Client) SetLightState(id string, state StateUpdate) error {
+	return c.update("/lights/"+id+"/state", state)
+}
+
+func (c *Client) RenameLight(id string, name string) error {
+	return c.update("/lights/"+id, map[string]string{"name": name})
+}
+
+func (c *Client) SetGroupState(id string, state StateUpdate) error {
+	return c.update("/groups/"+id+"/action", state)
+}
+
+func (c *Client) RenameGroup(id string, name string) error {
+	return c.update("/groups/"+id, map[string]string{"name": name})
+}
+
+func (c *Client) GetScenes() ([]Scene, error) {
+	var sceneMap map[string]Scene
+	err := c.get("/scenes", &sceneMap)
+	if err != nil {
+		return nil, err
+	}
+	scenes := make([]Scene, 0, len(sceneMap))
+	for id, scene := range sceneMap {
+		scene.ID = id
+		scenes = append(scenes, scene)
+	}
+	sort.Sort(scenesByID(scenes))
+	return scenes, nil
+}
+
+func (c *Client) GetScene(id string) (Scene, error) {
+	scene := Scene{ID: id}
+	err := c.get("/scenes/"+id, &scene)
+	return scene, err
+}
+
+// CreateScene creates a scene with its light states. Group scenes only need
+// a Group; light scenes need the list of Lights.
+func (c *Client) CreateScene(scene Scene) (string, error) {
+	req := map[string]interface{}{
+		"name":        scene.Name,
+		"recycle":     false,
+		"lightstates": scene.LightStates,
+	}
+	if scene.Type == GroupScene || (scene.Type == "" && scene.Group != "") {
+		req["type"] = GroupScene
+		req["group"] = scene.Group
+	} else {
+		req["type"] = LightScene
+		req["lights"] = scene.Lights
+	}
+	if scene.TransitionTime != 0 {
+		req["transitiontime"] = scene.TransitionTime
+	}
+	return c.create("/scenes", req)
+}
+
+func (c *Client) DeleteScene(id string) error {
+	return c.remove("/scenes/" + id)
+}
+
+// RecallScene applies a scene to the lights of a group, or to all its lights
+// for group 0.
+func (c *Client) RecallScene(groupID string, sceneID string) error {
+	return c.SetGroupState(groupID, StateUpdate{Scene: sceneID})
+}
+
+func (c *Client) GetSensors() ([]Sensor, error) {
+	var sensorMap map[string]Sensor
+	err := c.get("/sensors", &sensorMap)
+	if err != nil {
+		return nil, err
+	}
+	sensors := make([]Sensor, 0, len(sensorMap))
+	for id, sensor := range sensorMap {
+		sensor.ID = id
+		sensors = append(sensors, sensor)
+	}
+	sort.Sort(sensorsByID(sensors))
+	return sensors, nil
+}
+
+func (c *Client) GetSchedules() ([]Schedule, error) {
+	var scheduleMap map[string]Schedule
+	err := c.get("/schedules", &scheduleMap)
+	if err != nil {
+		return nil, err
+	}
+	schedules := make([]Schedule, 0, len(scheduleMap))
+	for id, schedule := range scheduleMap {
+		schedule.ID = id
+		schedules = append(schedules, schedule)
+	}
+	sort.Sort(schedulesByID(schedules))
+	return schedules, nil
+}
+
+func (c *Client) GetSchedule(id string) (Schedule, error) {
+	schedule := Schedule{ID: id}
+	err := c.get("/schedules/"+id, &schedule)
+	return schedule, err
+}
+
+func (c *Client) CreateSchedule(schedule Schedule) (string, error) {
+	return c.create("/schedules", scheduleRequest(schedule))
+}
+
+func (c *Client) UpdateSchedule(schedule Schedule) error {
+	return c.update("/schedules/"+schedule.ID, scheduleRequest(schedule))
+}
+
+func (c *Client) SetScheduleStatus(id string, status string) error {
+	return c.update("/schedules/"+id, map[string]string{"status": status})
+}
+
+func (c *Client) DeleteSchedule(id string) error {
+	return c.remove("/schedules/" + id)
+}
+
+// scheduleRequest keeps the writable attributes of a schedule.
+func scheduleRequest(schedule Schedule) map[string]interface{} {
+	req := map[string]interface{}{
+		"name":        schedule.Name,
+		"description": schedule.Description,
+		"command":     schedule.Command,
+		"localtime":   schedule.LocalTime,
+		"autodelete":  schedule.AutoDelete,
+	}
+	if schedule.Status != "" {
+		req["status"] = schedule.Status
+	}
+	return req
+}
@@ -0,0 +1,34 @@
+package hue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadErrorsAreBridgeErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"error":{"type":3,"address":"/lights/99","description":"resource, /lights/99, not available"}}]`))
+	}))
+	defer server.Close()
+
+	client := New(strings.TrimPrefix(server.URL, "http://"), "user")
+	_, err := client.GetLight("99")
+	assert.Equal(t, Error{Type: ErrCodesResourceNotAvailable, Address: "/lights/99", Description: "resource, /lights/99, not available"}, err)
+}
+
+func TestUpdateGroupLeavesOutState(t *testing.T) {
+	bridge := newFakeBridge(nil)
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	client := New(strings.TrimPrefix(server.URL, "http://"), "user")
+	group := Group{ID: "1", Name: "Office", Lights: []string{"1", "2"}, State: &GroupState{AllOn: true, AnyOn: true}}
+	assert.Nil(t, client.UpdateGroup(group))
+	assert.Equal(t, "Office", bridge.updated["/groups/1"]["Name"])
+	assert.NotContains(t, bridge.updated["/groups/1"], "state")
+	assert.NotNil(t, group.State)
+}
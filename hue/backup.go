@@ -0,0 +1,491 @@
+package hue
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupVersion is the version of the archive format written by Backup.
+// Restore refuses archives with a newer version.
+const BackupVersion = 1
+
+// Backup is a snapshot of everything stored on a bridge. Resources are kept
+// as raw JSON so that attributes this package does not know about survive a
+// backup/restore round trip. Whitelist users, which are the bridge's
+// credentials, are left out.
+type Backup struct {
+	Version       int                        `json:"version"`
+	Created       time.Time                  `json:"created"`
+	BridgeID      string                     `json:"bridgeid"`
+	Config        json.RawMessage            `json:"config"`
+	Lights        map[string]json.RawMessage `json:"lights"`
+	Groups        map[string]json.RawMessage `json:"groups"`
+	Scenes        map[string]json.RawMessage `json:"scenes"`
+	Schedules     map[string]json.RawMessage `json:"schedules"`
+	Rules         map[string]json.RawMessage `json:"rules"`
+	Sensors       map[string]json.RawMessage `json:"sensors"`
+	ResourceLinks map[string]json.RawMessage `json:"resourcelinks"`
+}
+
+// RestoreReport describes how the IDs of the backed up bridge map to the IDs
+// of the restored one, by resource type ("lights", "groups", ...).
+type RestoreReport struct {
+	IDs     map[string]map[string]string
+	Missing map[string][]string
+}
+
+func (c *Client) Backup() (Backup, error) {
+	backup := Backup{
+		Version: BackupVersion,
+		Created: time.Now().UTC(),
+	}
+
+	var config rawObject
+	if err := c.get("/config", &config); err != nil {
+		return Backup{}, fmt.Errorf("Backup: config: %v", err)
+	}
+	delete(config, "whitelist")
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return Backup{}, fmt.Errorf("Backup: config: %v", err)
+	}
+	backup.Config = configJSON
+	backup.BridgeID = stringAttr(config, "bridgeid")
+
+	resources := []struct {
+		path   string
+		result *map[string]json.RawMessage
+	}{
+		{"/lights", &backup.Lights},
+		{"/groups", &backup.Groups},
+		{"/scenes", &backup.Scenes},
+		{"/schedules", &backup.Schedules},
+		{"/rules", &backup.Rules},
+		{"/sensors", &backup.Sensors},
+		{"/resourcelinks", &backup.ResourceLinks},
+	}
+	for _, resource := range resources {
+		if err := c.get(resource.path, resource.result); err != nil {
+			return Backup{}, fmt.Errorf("Backup: %v: %v", resource.path, err)
+		}
+	}
+
+	// the scene list omits lightstates, which are only returned by a per-scene GET.
+	for id := range backup.Scenes {
+		var scene json.RawMessage
+		if err := c.get("/scenes/"+id, &scene); err != nil {
+			return Backup{}, fmt.Errorf("Backup: scene %v: %v", id, err)
+		}
+		backup.Scenes[id] = scene
+	}
+
+	return backup, nil
+}
+
+func (c *Client) Restore(backup Backup) (RestoreReport, error) {
+	if backup.Version > BackupVersion {
+		return RestoreReport{}, fmt.Errorf("Restore: unsupported backup version %v", backup.Version)
+	}
+
+	r := &restorer{
+		client: c,
+		backup: backup,
+		report: RestoreReport{
+			IDs:     map[string]map[string]string{},
+			Missing: map[string][]string{},
+		},
+	}
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"config", r.restoreConfig},
+		{"lights", r.restoreLights},
+		{"sensors", r.restoreSensors},
+		{"groups", r.restoreGroups},
+		{"scenes", r.restoreScenes},
+		{"schedules", r.restoreSchedules},
+		{"rules", r.restoreRules},
+		{"resourcelinks", r.restoreResourceLinks},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			return r.report, fmt.Errorf("Restore: %v: %v", step.name, err)
+		}
+	}
+
+	return r.report, nil
+}
+
+type restorer struct {
+	client *Client
+	backup Backup
+	report RestoreReport
+}
+
+type rawObject map[string]interface{}
+
+func (r *restorer) mapID(resource, oldID, newID string) {
+	ids, ok := r.report.IDs[resource]
+	if !ok {
+		ids = map[string]string{}
+		r.report.IDs[resource] = ids
+	}
+	ids[oldID] = newID
+}
+
+func (r *restorer) lookupID(resource, oldID string) (string, bool) {
+	newID, ok := r.report.IDs[resource][oldID]
+	return newID, ok
+}
+
+func (r *restorer) missing(resource, oldID string) {
+	log.Printf("restore: no match for %v %v on target bridge\n", resource, oldID)
+	r.report.Missing[resource] = append(r.report.Missing[resource], oldID)
+}
+
+func (r *restorer) restoreConfig() error {
+	var config rawObject
+	if err := json.Unmarshal(r.backup.Config, &config); err != nil {
+		return err
+	}
+	return r.client.update("/config", pick(config, "name", "timezone"))
+}
+
+func (r *restorer) restoreLights() error {
+	current, err := r.currentByUniqueID("/lights")
+	if err != nil {
+		return err
+	}
+
+	for _, oldID := range sortedIDs(r.backup.Lights) {
+		light, err := decodeObject(r.backup.Lights[oldID])
+		if err != nil {
+			return err
+		}
+		newID, found := current[stringAttr(light, "uniqueid")]
+		if !found {
+			r.missing("lights", oldID)
+			continue
+		}
+		r.mapID("lights", oldID, newID)
+		if err := r.client.update("/lights/"+newID, pick(light, "name")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreSensors() error {
+	current, err := r.currentByUniqueID("/sensors")
+	if err != nil {
+		return err
+	}
+	daylightID, err := r.currentDaylightSensor()
+	if err != nil {
+		return err
+	}
+
+	for _, oldID := range sortedIDs(r.backup.Sensors) {
+		sensor, err := decodeObject(r.backup.Sensors[oldID])
+		if err != nil {
+			return err
+		}
+		sensorType := stringAttr(sensor, "type")
+		newID, found := current[stringAttr(sensor, "uniqueid")]
+
+		if !found && sensorType == "Daylight" && daylightID != "" {
+			newID, found = daylightID, true
+		}
+
+		if found {
+			r.mapID("sensors", oldID, newID)
+			if err := r.client.update("/sensors/"+newID, pick(sensor, "name")); err != nil {
+				return err
+			}
+		} else if strings.HasPrefix(sensorType, "CLIP") {
+			body := pick(sensor, "name", "type", "modelid", "swversion", "uniqueid", "manufacturername", "state", "config", "recycle")
+			if err := r.create("sensors", oldID, body); err != nil {
+				return err
+			}
+		} else {
+			r.missing("sensors", oldID)
+		}
+	}
+	return nil
+}
+
+func (r *restorer) currentDaylightSensor() (string, error) {
+	var sensors map[string]struct{ Type string }
+	if err := r.client.get("/sensors", &sensors); err != nil {
+		return "", err
+	}
+	for id, sensor := range sensors {
+		if sensor.Type == "Daylight" {
+			return id, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *restorer) restoreGroups() error {
+	current, err := r.currentByUniqueID("/groups")
+	if err != nil {
+		return err
+	}
+
+	for _, oldID := range sortedIDs(r.backup.Groups) {
+		group, err := decodeObject(r.backup.Groups[oldID])
+		if err != nil {
+			return err
+		}
+
+		switch stringAttr(group, "type") {
+		case "Luminaire", "LightSource":
+			// created by the bridge itself for multisource luminaires.
+			if newID, found := current[stringAttr(group, "uniqueid")]; found {
+				r.mapID("groups", oldID, newID)
+			} else {
+				r.missing("groups", oldID)
+			}
+			continue
+		}
+
+		body := pick(group, "name", "type", "class", "lights", "sensors", "locations", "recycle")
+		body["lights"] = r.remapIDList("lights", body["lights"])
+		if sensors, ok := body["sensors"]; ok {
+			body["sensors"] = r.remapIDList("sensors", sensors)
+		}
+		if locations, ok := body["locations"].(map[string]interface{}); ok {
+			body["locations"] = r.remapIDKeys("lights", locations)
+		}
+		if err := r.create("groups", oldID, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreScenes() error {
+	for _, oldID := range sortedIDs(r.backup.Scenes) {
+		scene, err := decodeObject(r.backup.Scenes[oldID])
+		if err != nil {
+			return err
+		}
+
+		body := pick(scene, "name", "type", "group", "lights", "recycle", "appdata", "picture", "image", "lightstates", "transitiontime")
+		if group, ok := body["group"].(string); ok {
+			newGroupID, found := r.lookupID("groups", group)
+			if !found {
+				r.missing("scenes", oldID)
+				continue
+			}
+			body["group"] = newGroupID
+			// lights of a group scene are derived from the group.
+			delete(body, "lights")
+		} else {
+			body["lights"] = r.remapIDList("lights", body["lights"])
+		}
+		if lightStates, ok := body["lightstates"].(map[string]interface{}); ok {
+			body["lightstates"] = r.remapIDKeys("lights", lightStates)
+		}
+		if err := r.create("scenes", oldID, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreSchedules() error {
+	for _, oldID := range sortedIDs(r.backup.Schedules) {
+		schedule, err := decodeObject(r.backup.Schedules[oldID])
+		if err != nil {
+			return err
+		}
+
+		body := pick(schedule, "name", "description", "command", "localtime", "status", "autodelete", "recycle")
+		if command, ok := body["command"].(map[string]interface{}); ok {
+			body["command"] = r.remapCommand(command)
+		}
+		if err := r.create("schedules", oldID, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreRules() error {
+	for _, oldID := range sortedIDs(r.backup.Rules) {
+		rule, err := decodeObject(r.backup.Rules[oldID])
+		if err != nil {
+			return err
+		}
+
+		body := pick(rule, "name", "status", "recycle", "conditions", "actions")
+		if conditions, ok := body["conditions"].([]interface{}); ok {
+			for _, condition := range conditions {
+				if condition, ok := condition.(map[string]interface{}); ok {
+					r.remapCommand(condition)
+				}
+			}
+		}
+		if actions, ok := body["actions"].([]interface{}); ok {
+			for _, action := range actions {
+				if action, ok := action.(map[string]interface{}); ok {
+					r.remapCommand(action)
+				}
+			}
+		}
+		if err := r.create("rules", oldID, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreResourceLinks() error {
+	for _, oldID := range sortedIDs(r.backup.ResourceLinks) {
+		link, err := decodeObject(r.backup.ResourceLinks[oldID])
+		if err != nil {
+			return err
+		}
+
+		body := pick(link, "name", "description", "type", "classid", "links", "recycle")
+		if links, ok := body["links"].([]interface{}); ok {
+			remapped := make([]interface{}, 0, len(links))
+			for _, address := range links {
+				if address, ok := address.(string); ok {
+					remapped = append(remapped, r.remapAddress(address))
+				}
+			}
+			body["links"] = remapped
+		}
+		if err := r.create("resourcelinks", oldID, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) create(resource string, oldID string, body rawObject) error {
+	newID, err := r.client.create("/"+resource, body)
+	if err != nil {
+		return fmt.Errorf("%v %v: %v", resource, oldID, err)
+	}
+	r.mapID(resource, oldID, newID)
+	return nil
+}
+
+func (r *restorer) currentByUniqueID(path string) (map[string]string, error) {
+	var objects map[string]struct {
+		UniqueID string `json:"uniqueid"`
+	}
+	if err := r.client.get(path, &objects); err != nil {
+		return nil, err
+	}
+	ids := map[string]string{}
+	for id, object := range objects {
+		if object.UniqueID != "" {
+			ids[object.UniqueID] = id
+		}
+	}
+	return ids, nil
+}
+
+func (r *restorer) remapIDList(resource string, value interface{}) []string {
+	list, _ := value.([]interface{})
+	ids := make([]string, 0, len(list))
+	for _, id := range list {
+		if id, ok := id.(string); ok {
+			if newID, found := r.lookupID(resource, id); found {
+				ids = append(ids, newID)
+			}
+		}
+	}
+	return ids
+}
+
+func (r *restorer) remapIDKeys(resource string, values map[string]interface{}) map[string]interface{} {
+	remapped := map[string]interface{}{}
+	for id, value := range values {
+		if newID, found := r.lookupID(resource, id); found {
+			remapped[newID] = value
+		}
+	}
+	return remapped
+}
+
+// remapCommand rewrites the address of a schedule command, rule condition or
+// rule action, as well as any scene it recalls.
+func (r *restorer) remapCommand(command map[string]interface{}) map[string]interface{} {
+	if address, ok := command["address"].(string); ok {
+		command["address"] = r.remapAddress(address)
+	}
+	if body, ok := command["body"].(map[string]interface{}); ok {
+		if scene, ok := body["scene"].(string); ok {
+			if newID, found := r.lookupID("scenes", scene); found {
+				body["scene"] = newID
+			}
+		}
+	}
+	return command
+}
+
+var addressRe = regexp.MustCompile("^(/api/[^/]+)?/(lights|groups|scenes|schedules|sensors|rules|resourcelinks)/([^/]+)(.*)$")
+
+func (r *restorer) remapAddress(address string) string {
+	match := addressRe.FindStringSubmatch(address)
+	if match == nil {
+		return address
+	}
+	prefix, resource, id, rest := match[1], match[2], match[3], match[4]
+	if prefix != "" {
+		prefix = "/api/" + r.client.Username
+	}
+	if newID, found := r.lookupID(resource, id); found {
+		id = newID
+	}
+	return prefix + "/" + resource + "/" + id + rest
+}
+
+func decodeObject(raw json.RawMessage) (rawObject, error) {
+	var object rawObject
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, fmt.Errorf("unable to decode %s: %v", raw, err)
+	}
+	return object, nil
+}
+
+func stringAttr(object rawObject, key string) string {
+	str, _ := object[key].(string)
+	return str
+}
+
+// pick returns the subset of attributes of object that are writable on the bridge.
+func pick(object rawObject, keys ...string) rawObject {
+	picked := rawObject{}
+	for _, key := range keys {
+		if value, ok := object[key]; ok {
+			picked[key] = value
+		}
+	}
+	return picked
+}
+
+// sortedIDs returns the keys of objects in numeric order where possible, so
+// that restored resources are created in the same order they were on the
+// original bridge.
+func sortedIDs(objects map[string]json.RawMessage) []string {
+	ids := make([]string, 0, len(objects))
+	for id := range objects {
+		ids = append(ids, id)
+	}
+	sort.Sort(byNumericID(ids))
+	return ids
+}
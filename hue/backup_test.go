@@ -0,0 +1,170 @@
+package hue
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBridge answers GETs from fixed resources, records the bodies of POSTs
+// and PUTs, and assigns sequential IDs to created resources.
+type fakeBridge struct {
+	resources map[string]string
+	created   map[string][]rawObject
+	updated   map[string]rawObject
+	failPut   string
+	nextID    int
+}
+
+func newFakeBridge(resources map[string]string) *fakeBridge {
+	return &fakeBridge{
+		resources: resources,
+		created:   map[string][]rawObject{},
+		updated:   map[string]rawObject{},
+		nextID:    100,
+	}
+}
+
+func (b *fakeBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/user")
+	var body rawObject
+	if r.Method != "GET" {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	switch r.Method {
+	case "GET":
+		if res, ok := b.resources[path]; ok {
+			w.Write([]byte(res))
+		} else {
+			w.Write([]byte(`{}`))
+		}
+	case "POST":
+		b.created[path] = append(b.created[path], body)
+		b.nextID++
+		fmt.Fprintf(w, `[{"success":{"id":"%d"}}]`, b.nextID)
+	case "PUT":
+		b.updated[path] = body
+		if path == b.failPut {
+			fmt.Fprintf(w, `[{"error":{"type":7,"address":"%s/name","description":"invalid value"}}]`, path)
+		} else {
+			fmt.Fprintf(w, `[{"success":{"%s/name":"ok"}}]`, path)
+		}
+	}
+}
+
+func rawObjects(objects map[string]string) map[string]json.RawMessage {
+	raw := map[string]json.RawMessage{}
+	for id, object := range objects {
+		raw[id] = json.RawMessage(object)
+	}
+	return raw
+}
+
+func testBackup() Backup {
+	return Backup{
+		Version: BackupVersion,
+		Config:  json.RawMessage(`{"name":"Home","timezone":"Europe/Paris","bridgeid":"OLD"}`),
+		Lights: rawObjects(map[string]string{
+			"1": `{"name":"Desk","uniqueid":"aa"}`,
+			"2": `{"name":"Lamp","uniqueid":"bb"}`,
+			"3": `{"name":"Gone","uniqueid":"cc"}`,
+		}),
+		Sensors: rawObjects(map[string]string{
+			"1": `{"name":"Daylight","type":"Daylight"}`,
+			"5": `{"name":"Flag","type":"CLIPGenericFlag","uniqueid":"flag"}`,
+		}),
+		Groups: rawObjects(map[string]string{
+			"1": `{"name":"Office","type":"Room","lights":["1","2","3"],"locations":{"1":[0,0,0],"3":[1,1,1]}}`,
+		}),
+		Scenes: rawObjects(map[string]string{
+			"abc":    `{"name":"Relax","type":"GroupScene","group":"1","lights":["1","2"],"lightstates":{"1":{"on":true},"3":{"on":false}}}`,
+			"orphan": `{"name":"Orphan","type":"GroupScene","group":"9"}`,
+		}),
+		Schedules: rawObjects(map[string]string{
+			"1": `{"name":"Wake","command":{"address":"/api/olduser/groups/1/action","method":"PUT","body":{"scene":"abc"}},"localtime":"W127/T07:00:00"}`,
+		}),
+		ResourceLinks: rawObjects(map[string]string{
+			"1": `{"name":"Routine","classid":1,"links":["/groups/1","/scenes/abc","/schedules/1","/lights/3"]}`,
+		}),
+	}
+}
+
+func TestRestoreRemapsIDs(t *testing.T) {
+	bridge := newFakeBridge(map[string]string{
+		"/lights":  `{"7":{"uniqueid":"aa"},"8":{"uniqueid":"bb"}}`,
+		"/sensors": `{"1":{"type":"Daylight"}}`,
+	})
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	client := New(strings.TrimPrefix(server.URL, "http://"), "user")
+	report, err := client.Restore(testBackup())
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]string{"1": "7", "2": "8"}, report.IDs["lights"])
+	assert.Equal(t, map[string]string{"1": "1", "5": "101"}, report.IDs["sensors"])
+	assert.Equal(t, map[string]string{"1": "102"}, report.IDs["groups"])
+	assert.Equal(t, map[string]string{"abc": "103"}, report.IDs["scenes"])
+	assert.Equal(t, map[string]string{"1": "104"}, report.IDs["schedules"])
+	assert.Equal(t, []string{"3"}, report.Missing["lights"])
+	assert.Equal(t, []string{"orphan"}, report.Missing["scenes"])
+
+	assert.Equal(t, rawObject{"name": "Home", "timezone": "Europe/Paris"}, bridge.updated["/config"])
+	assert.Equal(t, rawObject{"name": "Desk"}, bridge.updated["/lights/7"])
+	assert.Equal(t, rawObject{"name": "Daylight"}, bridge.updated["/sensors/1"])
+
+	group := bridge.created["/groups"][0]
+	assert.Equal(t, []interface{}{"7", "8"}, group["lights"])
+	assert.Equal(t, map[string]interface{}{"7": []interface{}{0.0, 0.0, 0.0}}, group["locations"])
+
+	scene := bridge.created["/scenes"][0]
+	assert.Equal(t, "102", scene["group"])
+	assert.NotContains(t, scene, "lights")
+	assert.Equal(t, map[string]interface{}{"7": map[string]interface{}{"on": true}}, scene["lightstates"])
+
+	command := bridge.created["/schedules"][0]["command"].(map[string]interface{})
+	assert.Equal(t, "/api/user/groups/102/action", command["address"])
+	assert.Equal(t, map[string]interface{}{"scene": "103"}, command["body"])
+
+	links := bridge.created["/resourcelinks"][0]["links"]
+	assert.Equal(t, []interface{}{"/groups/102", "/scenes/103", "/schedules/104", "/lights/3"}, links)
+}
+
+func TestRestoreFailsOnBridgeErrors(t *testing.T) {
+	bridge := newFakeBridge(map[string]string{
+		"/lights": `{"7":{"uniqueid":"aa"}}`,
+	})
+	bridge.failPut = "/lights/7"
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	client := New(strings.TrimPrefix(server.URL, "http://"), "user")
+	_, err := client.Restore(testBackup())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Restore: lights:")
+	assert.Empty(t, bridge.created)
+}
+
+func TestBackupOmitsWhitelist(t *testing.T) {
+	bridge := newFakeBridge(map[string]string{
+		"/config": `{"name":"Home","bridgeid":"001788FFFE000000","whitelist":{"user":{"name":"huecontrol"}}}`,
+	})
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	client := New(strings.TrimPrefix(server.URL, "http://"), "user")
+	backup, err := client.Backup()
+	assert.Nil(t, err)
+	assert.Equal(t, "001788FFFE000000", backup.BridgeID)
+	assert.NotContains(t, string(backup.Config), "whitelist")
+
+	data, err := json.Marshal(backup)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), `"user"`)
+}
@@ -0,0 +1,119 @@
+// Package clipv2 is a client for the CLIP v2 API of newer Hue bridges.
+package clipv2
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+const appKeyHeader = "hue-application-key"
+
+type Client struct {
+	rootURL  string
+	Hostname string
+	AppKey   string
+
+	client *http.Client
+}
+
+// New creates a client for the bridge at hostname. Bridges serve CLIP v2 over
+// https with a self-signed certificate, which is not verified; use NewWithTLS
+// with hue.CertificatePin to verify it.
+func New(hostname string, appKey string) *Client {
+	return NewWithTLS(hostname, appKey, &tls.Config{InsecureSkipVerify: true})
+}
+
+func NewWithTLS(hostname string, appKey string, config *tls.Config) *Client {
+	transport := &http.Transport{TLSClientConfig: config}
+	return &Client{
+		client:   &http.Client{Transport: transport},
+		rootURL:  fmt.Sprintf("https://%v", hostname),
+		AppKey:   appKey,
+		Hostname: hostname,
+	}
+}
+
+type response struct {
+	Errors []struct {
+		Description string
+	}
+	Data json.RawMessage
+}
+
+func (c *Client) get(path string, resObject interface{}) error {
+	return c.do("GET", path, nil, resObject)
+}
+
+func (c *Client) post(path string, reqObject interface{}, resObject interface{}) error {
+	return c.do("POST", path, reqObject, resObject)
+}
+
+func (c *Client) put(path string, reqObject interface{}, resObject interface{}) error {
+	return c.do("PUT", path, reqObject, resObject)
+}
+
+func (c *Client) delete(path string, resObject interface{}) error {
+	return c.do("DELETE", path, nil, resObject)
+}
+
+func (c *Client) do(method string, path string, reqObject interface{}, resObject interface{}) error {
+	url := c.url(path)
+	error := func(msg string, err error) error {
+		return fmt.Errorf("clipv2.Client %v %v: %v: %v", method, url, msg, err)
+	}
+	var reqBody io.Reader = nil
+	if reqObject != nil {
+		reqBytes, err := json.Marshal(reqObject)
+		if err != nil {
+			return error("encoding", err)
+		}
+		reqBody = bytes.NewReader(reqBytes)
+	}
+	log.Printf("do %v %v\n", method, url)
+
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return error("request", err)
+	}
+	req.Header.Add("content-type", "application/json")
+	req.Header.Add(appKeyHeader, c.AppKey)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return error("request", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return error("read", err)
+	}
+
+	var envelope response
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return error("decoding", fmt.Errorf("%v: %v", string(body), err))
+	}
+
+	if len(envelope.Errors) > 0 {
+		return Error{StatusCode: res.StatusCode, Description: envelope.Errors[0].Description}
+	} else if res.StatusCode >= 300 {
+		return error("read", fmt.Errorf("unexpected status code %v. Body: %v", res.StatusCode, string(body)))
+	}
+
+	if resObject != nil {
+		if err := json.Unmarshal(envelope.Data, resObject); err != nil {
+			return error("decoding", fmt.Errorf("%v: %v", string(envelope.Data), err))
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) url(path string) string {
+	return fmt.Sprintf("%v%v", c.rootURL, path)
+}
@@ -0,0 +1,202 @@
+package clipv2
+
+import (
+	"fmt"
+	"strings"
+)
+
+const resourcePath = "/clip/v2/resource"
+
+// Error is an error reported by the bridge in the "errors" field of a response.
+type Error struct {
+	StatusCode  int
+	Description string
+}
+
+func (err Error) Error() string {
+	return fmt.Sprintf("bridge error: status: %v; description: %v", err.StatusCode, err.Description)
+}
+
+func (c *Client) getResources(rtype string, resObject interface{}) error {
+	return c.get(resourcePath+"/"+rtype, resObject)
+}
+
+// getResource fetches a single resource. The bridge wraps it in a list, which
+// is unpacked into the first element of resList.
+func (c *Client) getResource(rtype string, id string, resList interface{}) error {
+	return c.get(resourcePath+"/"+rtype+"/"+id, resList)
+}
+
+func (c *Client) updateResource(rtype string, id string, update interface{}) error {
+	return c.put(resourcePath+"/"+rtype+"/"+id, update, nil)
+}
+
+func notFound(rtype, id string) error {
+	return fmt.Errorf("clipv2: %v %v not found", rtype, id)
+}
+
+func (c *Client) GetLights() ([]Light, error) {
+	var lights []Light
+	err := c.getResources("light", &lights)
+	return lights, err
+}
+
+func (c *Client) GetLight(id string) (Light, error) {
+	var lights []Light
+	if err := c.getResource("light", id, &lights); err != nil {
+		return Light{}, err
+	} else if len(lights) == 0 {
+		return Light{}, notFound("light", id)
+	}
+	return lights[0], nil
+}
+
+func (c *Client) UpdateLight(id string, update LightUpdate) error {
+	return c.updateResource("light", id, update)
+}
+
+func (c *Client) GetRooms() ([]Group, error) {
+	var rooms []Group
+	err := c.getResources("room", &rooms)
+	return rooms, err
+}
+
+func (c *Client) GetZones() ([]Group, error) {
+	var zones []Group
+	err := c.getResources("zone", &zones)
+	return zones, err
+}
+
+func (c *Client) GetGroupedLights() ([]GroupedLight, error) {
+	var groupedLights []GroupedLight
+	err := c.getResources("grouped_light", &groupedLights)
+	return groupedLights, err
+}
+
+func (c *Client) GetGroupedLight(id string) (GroupedLight, error) {
+	var groupedLights []GroupedLight
+	if err := c.getResource("grouped_light", id, &groupedLights); err != nil {
+		return GroupedLight{}, err
+	} else if len(groupedLights) == 0 {
+		return GroupedLight{}, notFound("grouped_light", id)
+	}
+	return groupedLights[0], nil
+}
+
+func (c *Client) UpdateGroupedLight(id string, update LightUpdate) error {
+	return c.updateResource("grouped_light", id, update)
+}
+
+func (c *Client) GetScenes() ([]Scene, error) {
+	var scenes []Scene
+	err := c.getResources("scene", &scenes)
+	return scenes, err
+}
+
+func (c *Client) GetScene(id string) (Scene, error) {
+	var scenes []Scene
+	if err := c.getResource("scene", id, &scenes); err != nil {
+		return Scene{}, err
+	} else if len(scenes) == 0 {
+		return Scene{}, notFound("scene", id)
+	}
+	return scenes[0], nil
+}
+
+func (c *Client) CreateScene(scene Scene) (string, error) {
+	var created []ResourceIdentifier
+	if err := c.post(resourcePath+"/scene", scene, &created); err != nil {
+		return "", err
+	} else if len(created) == 0 {
+		return "", fmt.Errorf("clipv2: CreateScene: no id in response")
+	}
+	return created[0].RID, nil
+}
+
+// RecallScene activates a scene; action is one of "active", "dynamic_palette" or "static".
+func (c *Client) RecallScene(id string, action string) error {
+	update := map[string]interface{}{
+		"recall": map[string]string{"action": action},
+	}
+	return c.updateResource("scene", id, update)
+}
+
+func (c *Client) DeleteScene(id string) error {
+	return c.delete(resourcePath+"/scene/"+id, nil)
+}
+
+func (c *Client) GetDevices() ([]Device, error) {
+	var devices []Device
+	err := c.getResources("device", &devices)
+	return devices, err
+}
+
+func (c *Client) GetMotions() ([]Motion, error) {
+	var motions []Motion
+	err := c.getResources("motion", &motions)
+	return motions, err
+}
+
+func (c *Client) GetButtons() ([]Button, error) {
+	var buttons []Button
+	err := c.getResources("button", &buttons)
+	return buttons, err
+}
+
+// IDMap maps between v1 resource paths such as "/lights/3" and v2 resources.
+// Several v2 resources may share a v1 ID: a v1 light is both a v2 light and a
+// v2 device.
+type IDMap struct {
+	byV1 map[string][]ResourceIdentifier
+	byV2 map[string]string
+}
+
+func (c *Client) GetIDMap() (IDMap, error) {
+	var resources []Resource
+	if err := c.get(resourcePath, &resources); err != nil {
+		return IDMap{}, err
+	}
+	return NewIDMap(resources), nil
+}
+
+func NewIDMap(resources []Resource) IDMap {
+	m := IDMap{
+		byV1: map[string][]ResourceIdentifier{},
+		byV2: map[string]string{},
+	}
+	for _, resource := range resources {
+		if resource.IDV1 == "" {
+			continue
+		}
+		ref := ResourceIdentifier{RID: resource.ID, RType: resource.Type}
+		m.byV1[resource.IDV1] = append(m.byV1[resource.IDV1], ref)
+		m.byV2[resource.ID] = resource.IDV1
+	}
+	return m
+}
+
+// V2 returns the UUID of the v2 resource of type rtype for the given v1 path,
+// e.g. V2("/lights/3", "light").
+func (m IDMap) V2(idV1 string, rtype string) (string, bool) {
+	for _, ref := range m.byV1[idV1] {
+		if ref.RType == rtype {
+			return ref.RID, true
+		}
+	}
+	return "", false
+}
+
+// V1 returns the v1 path of a v2 resource, e.g. "/lights/3".
+func (m IDMap) V1(id string) (string, bool) {
+	idV1, ok := m.byV2[id]
+	return idV1, ok
+}
+
+// SplitV1 splits a v1 path such as "/lights/3" into its collection and ID.
+func SplitV1(idV1 string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(idV1, "/"), "/", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,188 @@
+package clipv2
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cannedResponse struct {
+	status int
+	body   string
+}
+
+// apiStandIn answers each "METHOD path" with a canned response, and records
+// the requests it gets.
+type apiStandIn struct {
+	responses map[string]cannedResponse
+	requests  []string
+	bodies    []string
+	appKeys   []string
+}
+
+func (s *apiStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + r.URL.Path
+	body, _ := ioutil.ReadAll(r.Body)
+	s.requests = append(s.requests, key)
+	s.bodies = append(s.bodies, string(body))
+	s.appKeys = append(s.appKeys, r.Header.Get(appKeyHeader))
+
+	res, ok := s.responses[key]
+	if !ok {
+		res = cannedResponse{http.StatusNotFound, `{"errors":[{"description":"not found"}],"data":[]}`}
+	}
+	w.WriteHeader(res.status)
+	w.Write([]byte(res.body))
+}
+
+func setupAPITest(responses map[string]cannedResponse) (*apiStandIn, *httptest.Server, *Client) {
+	standIn := &apiStandIn{responses: responses}
+	server := httptest.NewTLSServer(standIn)
+	client := New(strings.TrimPrefix(server.URL, "https://"), testAppKey)
+	return standIn, server, client
+}
+
+func TestGetDecodesEnvelope(t *testing.T) {
+	standIn, server, client := setupAPITest(map[string]cannedResponse{
+		"GET /clip/v2/resource/light": {200, `{"errors":[],"data":[
+			{"id":"a1","id_v1":"/lights/1","metadata":{"name":"Desk"},"on":{"on":true},"dimming":{"brightness":42.5}},
+			{"id":"b2","id_v1":"/lights/2","metadata":{"name":"Lamp"},"on":{"on":false}}
+		]}`},
+	})
+	defer server.Close()
+
+	lights, err := client.GetLights()
+	assert.Nil(t, err)
+	if assert.Len(t, lights, 2) {
+		assert.Equal(t, "Desk", lights[0].Metadata.Name)
+		assert.True(t, lights[0].On.On)
+		assert.Equal(t, 42.5, lights[0].Dimming.Brightness)
+		assert.Nil(t, lights[1].Dimming)
+	}
+	assert.Equal(t, []string{testAppKey}, standIn.appKeys)
+}
+
+func TestGetUnpacksSingleResource(t *testing.T) {
+	_, server, client := setupAPITest(map[string]cannedResponse{
+		"GET /clip/v2/resource/light/a1":  {200, `{"errors":[],"data":[{"id":"a1","metadata":{"name":"Desk"}}]}`},
+		"GET /clip/v2/resource/scene/old": {200, `{"errors":[],"data":[]}`},
+	})
+	defer server.Close()
+
+	light, err := client.GetLight("a1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Desk", light.Metadata.Name)
+
+	_, err = client.GetScene("old")
+	assert.Equal(t, notFound("scene", "old"), err)
+}
+
+func TestErrorsAreDecoded(t *testing.T) {
+	_, server, client := setupAPITest(map[string]cannedResponse{
+		"PUT /clip/v2/resource/light/a1": {207, `{"errors":[{"description":"device (light) is \"soft off\""}],"data":[]}`},
+		"GET /clip/v2/resource/room":     {503, `{"errors":[],"data":[]}`},
+		"GET /clip/v2/resource/zone":     {200, `<html>not json</html>`},
+	})
+	defer server.Close()
+
+	err := client.UpdateLight("a1", LightUpdate{On: &On{On: true}})
+	assert.Equal(t, Error{StatusCode: 207, Description: `device (light) is "soft off"`}, err)
+
+	_, err = client.GetLight("missing")
+	assert.Equal(t, Error{StatusCode: 404, Description: "not found"}, err)
+
+	_, err = client.GetRooms()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unexpected status code 503")
+	}
+
+	_, err = client.GetZones()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "decoding")
+	}
+}
+
+func TestUpdatesSendOnlySetFields(t *testing.T) {
+	standIn, server, client := setupAPITest(map[string]cannedResponse{
+		"PUT /clip/v2/resource/grouped_light/g1": {200, `{"errors":[],"data":[{"rid":"g1","rtype":"grouped_light"}]}`},
+		"PUT /clip/v2/resource/scene/s1":         {200, `{"errors":[],"data":[{"rid":"s1","rtype":"scene"}]}`},
+	})
+	defer server.Close()
+
+	err := client.UpdateGroupedLight("g1", LightUpdate{On: &On{On: false}, Dimming: &DimmingUpdate{Brightness: 0}})
+	assert.Nil(t, err)
+	err = client.RecallScene("s1", "active")
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"PUT /clip/v2/resource/grouped_light/g1", "PUT /clip/v2/resource/scene/s1"}, standIn.requests)
+	assert.Equal(t, `{"on":{"on":false},"dimming":{"brightness":0}}`, standIn.bodies[0])
+	assert.Equal(t, `{"recall":{"action":"active"}}`, standIn.bodies[1])
+}
+
+func TestCreateScene(t *testing.T) {
+	_, server, client := setupAPITest(map[string]cannedResponse{
+		"POST /clip/v2/resource/scene": {200, `{"errors":[],"data":[{"rid":"new-scene","rtype":"scene"}]}`},
+	})
+	defer server.Close()
+
+	id, err := client.CreateScene(Scene{Metadata: Metadata{Name: "Relax"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "new-scene", id)
+}
+
+func TestGetIDMap(t *testing.T) {
+	_, server, client := setupAPITest(map[string]cannedResponse{
+		"GET /clip/v2/resource": {200, `{"errors":[],"data":[
+			{"id":"light-3","id_v1":"/lights/3","type":"light"},
+			{"id":"device-3","id_v1":"/lights/3","type":"device"},
+			{"id":"room-1","id_v1":"/groups/1","type":"room"},
+			{"id":"grouped-1","id_v1":"/groups/1","type":"grouped_light"},
+			{"id":"bridge","id_v1":"","type":"bridge"}
+		]}`},
+	})
+	defer server.Close()
+
+	ids, err := client.GetIDMap()
+	assert.Nil(t, err)
+
+	id, ok := ids.V2("/lights/3", "light")
+	assert.True(t, ok)
+	assert.Equal(t, "light-3", id)
+	id, ok = ids.V2("/lights/3", "device")
+	assert.True(t, ok)
+	assert.Equal(t, "device-3", id)
+	id, ok = ids.V2("/groups/1", "grouped_light")
+	assert.True(t, ok)
+	assert.Equal(t, "grouped-1", id)
+	_, ok = ids.V2("/lights/3", "motion")
+	assert.False(t, ok)
+	_, ok = ids.V2("/lights/4", "light")
+	assert.False(t, ok)
+
+	idV1, ok := ids.V1("room-1")
+	assert.True(t, ok)
+	assert.Equal(t, "/groups/1", idV1)
+	_, ok = ids.V1("bridge")
+	assert.False(t, ok)
+}
+
+func TestSplitV1(t *testing.T) {
+	tests := []struct {
+		idV1, collection, id string
+	}{
+		{"/lights/3", "lights", "3"},
+		{"/groups/0", "groups", "0"},
+		{"/sensors/12/state", "sensors", "12/state"},
+		{"/config", "config", ""},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		collection, id := SplitV1(test.idV1)
+		assert.Equal(t, test.collection, collection, test.idV1)
+		assert.Equal(t, test.id, id, test.idV1)
+	}
+}
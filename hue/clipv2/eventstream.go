@@ -0,0 +1,219 @@
+package clipv2
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	eventStreamPath         = "/eventstream/clip/v2"
+	defaultEventStreamRetry = time.Second
+	maxEventSize            = 1024 * 1024
+)
+
+type EventType string
+
+const (
+	EventUpdate EventType = "update"
+	EventAdd    EventType = "add"
+	EventDelete EventType = "delete"
+	EventError  EventType = "error"
+)
+
+// Event is one change pushed by the bridge. A single event may carry several
+// resources, e.g. all the lights of a group turned on at once.
+type Event struct {
+	ID           string      `json:"id"`
+	CreationTime time.Time   `json:"creationtime"`
+	Type         EventType   `json:"type"`
+	Data         []EventData `json:"data"`
+}
+
+// EventData is a (partial, for updates) resource. Use Decode to read it as a
+// Light, Button, Motion, etc.
+type EventData struct {
+	Resource
+	Owner *ResourceIdentifier
+	Raw   json.RawMessage
+}
+
+func (d *EventData) UnmarshalJSON(b []byte) error {
+	var fields struct {
+		Resource
+		Owner *ResourceIdentifier `json:"owner"`
+	}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	d.Resource = fields.Resource
+	d.Owner = fields.Owner
+	d.Raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+func (d EventData) Decode(resObject interface{}) error {
+	return json.Unmarshal(d.Raw, resObject)
+}
+
+// EventStream consumes the bridge's server-sent events, reconnecting with the
+// ID of the last event received whenever the connection drops.
+type EventStream struct {
+	Events <-chan Event
+	Errors <-chan error
+
+	client      *Client
+	events      chan Event
+	errors      chan error
+	cancel      context.CancelFunc
+	lastEventID string
+	retry       time.Duration
+}
+
+func (c *Client) StreamEvents() *EventStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event)
+	errors := make(chan error, 1)
+	s := &EventStream{
+		Events: events,
+		Errors: errors,
+		client: c,
+		events: events,
+		errors: errors,
+		cancel: cancel,
+		retry:  defaultEventStreamRetry,
+	}
+	go s.run(ctx)
+	return s
+}
+
+// Close stops the stream. Events is closed once the stream is done.
+func (s *EventStream) Close() {
+	s.cancel()
+}
+
+func (s *EventStream) run(ctx context.Context) {
+	defer close(s.events)
+	for {
+		err := s.consume(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		s.reportError(err)
+		log.Printf("event stream %v: reconnecting in %v\n", s.client.Hostname, s.retry)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.retry):
+		}
+	}
+}
+
+// reportError never blocks: errors nobody reads are only logged.
+func (s *EventStream) reportError(err error) {
+	log.Printf("event stream %v: %v\n", s.client.Hostname, err)
+	select {
+	case s.errors <- err:
+	default:
+	}
+}
+
+func (s *EventStream) consume(ctx context.Context) error {
+	url := s.client.url(eventStreamPath)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("clipv2.EventStream %v: request: %v", url, err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("accept", "text/event-stream")
+	req.Header.Add(appKeyHeader, s.client.AppKey)
+	if s.lastEventID != "" {
+		req.Header.Add("last-event-id", s.lastEventID)
+	}
+
+	res, err := s.client.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("clipv2.EventStream %v: request: %v", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("clipv2.EventStream %v: unexpected status code %v", url, res.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 4096), maxEventSize)
+	var id string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := s.dispatch(ctx, id, data); err != nil {
+				return err
+			}
+			id, data = "", nil
+			continue
+		}
+
+		field, value := parseEventLine(line)
+		switch field {
+		case "id":
+			id = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			if millis, err := strconv.Atoi(value); err == nil {
+				s.retry = time.Duration(millis) * time.Millisecond
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("clipv2.EventStream %v: read: %v", url, err)
+	}
+	return fmt.Errorf("clipv2.EventStream %v: connection closed", url)
+}
+
+func parseEventLine(line string) (string, string) {
+	if strings.HasPrefix(line, ":") {
+		return "", ""
+	}
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], strings.TrimPrefix(parts[1], " ")
+}
+
+// dispatch decodes the data of one SSE message, which the bridge sends as a list of events.
+func (s *EventStream) dispatch(ctx context.Context, id string, data []string) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var events []Event
+	payload := strings.Join(data, "\n")
+	if err := json.Unmarshal([]byte(payload), &events); err != nil {
+		s.reportError(fmt.Errorf("clipv2.EventStream: decoding %v: %v", payload, err))
+	}
+
+	for _, event := range events {
+		select {
+		case s.events <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if id != "" {
+		s.lastEventID = id
+	}
+	return nil
+}
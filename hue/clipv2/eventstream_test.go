@@ -0,0 +1,139 @@
+package clipv2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testAppKey = "test-app-key"
+
+// sseStandIn serves one canned stream per connection, then closes it.
+type sseStandIn struct {
+	sync.Mutex
+	streams      []string
+	lastEventIDs []string
+	appKeys      []string
+}
+
+func (s *sseStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != eventStreamPath {
+		http.NotFound(w, r)
+		return
+	}
+	s.Lock()
+	s.lastEventIDs = append(s.lastEventIDs, r.Header.Get("Last-Event-ID"))
+	s.appKeys = append(s.appKeys, r.Header.Get(appKeyHeader))
+	conn := len(s.lastEventIDs) - 1
+	s.Unlock()
+
+	if conn >= len(s.streams) {
+		// keep the stream open and idle until the client goes away.
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprint(w, s.streams[conn])
+	w.(http.Flusher).Flush()
+}
+
+func setupEventStreamTest(streams ...string) (*sseStandIn, *httptest.Server, *Client) {
+	standIn := &sseStandIn{streams: streams}
+	server := httptest.NewTLSServer(standIn)
+	client := New(strings.TrimPrefix(server.URL, "https://"), testAppKey)
+	return standIn, server, client
+}
+
+func nextEvent(t *testing.T, stream *EventStream) Event {
+	select {
+	case event := <-stream.Events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
+
+func TestEventStreamDecodesEvents(t *testing.T) {
+	stream := ": hi\n\n" +
+		"retry: 10\n" +
+		"id: 1634576695:0\n" +
+		`data: [{"creationtime":"2021-10-18T17:04:55Z","id":"e1","type":"update","data":[` +
+		`{"id":"b1","id_v1":"/sensors/5","type":"button","owner":{"rid":"d1","rtype":"device"},"button":{"last_event":"short_release"}},` +
+		`{"id":"l1","id_v1":"/lights/3","type":"light","on":{"on":true}}]}]` + "\n\n"
+
+	_, server, client := setupEventStreamTest(stream)
+	defer server.Close()
+	events := client.StreamEvents()
+	defer events.Close()
+
+	event := nextEvent(t, events)
+	assert.Equal(t, "e1", event.ID)
+	assert.Equal(t, EventUpdate, event.Type)
+	assert.Equal(t, time.Date(2021, 10, 18, 17, 4, 55, 0, time.UTC), event.CreationTime)
+	assert.Len(t, event.Data, 2)
+
+	assert.Equal(t, "button", event.Data[0].Type)
+	assert.Equal(t, "/sensors/5", event.Data[0].IDV1)
+	assert.Equal(t, &ResourceIdentifier{RID: "d1", RType: "device"}, event.Data[0].Owner)
+	var button Button
+	assert.Nil(t, event.Data[0].Decode(&button))
+	assert.Equal(t, "short_release", button.Button.LastEvent)
+
+	var light Light
+	assert.Nil(t, event.Data[1].Decode(&light))
+	assert.Equal(t, "l1", light.ID)
+	assert.True(t, light.On.On)
+}
+
+func TestEventStreamReconnectsWithLastEventID(t *testing.T) {
+	first := "retry: 10\n" +
+		"id: 100:0\n" +
+		`data: [{"id":"e1","type":"add","data":[{"id":"s1","type":"scene"}]}]` + "\n\n"
+	second := "id: 101:0\n" +
+		`data: [{"id":"e2","type":"delete","data":[{"id":"s1","type":"scene"}]},` + "\n" +
+		`data: {"id":"e3","type":"error","data":[{"id":"l1","type":"light"}]}]` + "\n\n"
+
+	standIn, server, client := setupEventStreamTest(first, second)
+	defer server.Close()
+	events := client.StreamEvents()
+
+	assert.Equal(t, EventAdd, nextEvent(t, events).Type)
+
+	event := nextEvent(t, events)
+	assert.Equal(t, EventDelete, event.Type)
+	assert.Equal(t, "s1", event.Data[0].ID)
+
+	event = nextEvent(t, events)
+	assert.Equal(t, EventError, event.Type)
+	assert.Equal(t, "l1", event.Data[0].ID)
+
+	select {
+	case err := <-events.Errors:
+		assert.NotNil(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the dropped connection to be reported")
+	}
+
+	events.Close()
+	for range events.Events {
+	}
+
+	standIn.Lock()
+	defer standIn.Unlock()
+	assert.True(t, len(standIn.lastEventIDs) >= 2)
+	assert.Equal(t, "", standIn.lastEventIDs[0])
+	assert.Equal(t, "100:0", standIn.lastEventIDs[1])
+	for _, appKey := range standIn.appKeys {
+		assert.Equal(t, testAppKey, appKey)
+	}
+}
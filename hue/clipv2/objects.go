@@ -0,0 +1,227 @@
+package clipv2
+
+// ResourceIdentifier references another resource, e.g. the owner of a light
+// or the children of a room.
+type ResourceIdentifier struct {
+	RID   string `json:"rid"`
+	RType string `json:"rtype"`
+}
+
+type Metadata struct {
+	Name      string `json:"name,omitempty"`
+	Archetype string `json:"archetype,omitempty"`
+}
+
+type On struct {
+	On bool `json:"on"`
+}
+
+type Dimming struct {
+	Brightness  float64 `json:"brightness"`
+	MinDimLevel float64 `json:"min_dim_level,omitempty"`
+}
+
+type XY struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type Color struct {
+	XY        XY     `json:"xy"`
+	Gamut     *Gamut `json:"gamut,omitempty"`
+	GamutType string `json:"gamut_type,omitempty"`
+}
+
+type Gamut struct {
+	Red   XY `json:"red"`
+	Green XY `json:"green"`
+	Blue  XY `json:"blue"`
+}
+
+type ColorTemperature struct {
+	Mirek       *int `json:"mirek"`
+	MirekValid  bool `json:"mirek_valid"`
+	MirekSchema struct {
+		MirekMinimum int `json:"mirek_minimum"`
+		MirekMaximum int `json:"mirek_maximum"`
+	} `json:"mirek_schema"`
+}
+
+type Dynamics struct {
+	Status       string   `json:"status"`
+	StatusValues []string `json:"status_values"`
+	Speed        float64  `json:"speed"`
+	SpeedValid   bool     `json:"speed_valid"`
+}
+
+type GradientPoint struct {
+	Color struct {
+		XY XY `json:"xy"`
+	} `json:"color"`
+}
+
+type Gradient struct {
+	Points        []GradientPoint `json:"points"`
+	PointsCapable int             `json:"points_capable"`
+}
+
+type Effects struct {
+	Effect       string   `json:"effect"`
+	EffectValues []string `json:"effect_values"`
+	Status       string   `json:"status"`
+	StatusValues []string `json:"status_values"`
+}
+
+type Light struct {
+	ID               string             `json:"id"`
+	IDV1             string             `json:"id_v1"`
+	Owner            ResourceIdentifier `json:"owner"`
+	Metadata         Metadata           `json:"metadata"`
+	On               On                 `json:"on"`
+	Dimming          *Dimming           `json:"dimming"`
+	ColorTemperature *ColorTemperature  `json:"color_temperature"`
+	Color            *Color             `json:"color"`
+	Dynamics         *Dynamics          `json:"dynamics"`
+	Gradient         *Gradient          `json:"gradient"`
+	Effects          *Effects           `json:"effects"`
+	Mode             string             `json:"mode"`
+}
+
+// LightUpdate is the body of a light or grouped_light update. Only the non-nil
+// fields are sent to the bridge.
+type LightUpdate struct {
+	Metadata         *Metadata               `json:"metadata,omitempty"`
+	On               *On                     `json:"on,omitempty"`
+	Dimming          *DimmingUpdate          `json:"dimming,omitempty"`
+	DimmingDelta     *DimmingDelta           `json:"dimming_delta,omitempty"`
+	ColorTemperature *ColorTemperatureUpdate `json:"color_temperature,omitempty"`
+	Color            *ColorUpdate            `json:"color,omitempty"`
+	Dynamics         *DynamicsUpdate         `json:"dynamics,omitempty"`
+	Alert            *AlertUpdate            `json:"alert,omitempty"`
+	Gradient         *GradientUpdate         `json:"gradient,omitempty"`
+	Effects          *EffectsUpdate          `json:"effects,omitempty"`
+}
+
+type DimmingUpdate struct {
+	Brightness float64 `json:"brightness"`
+}
+
+type DimmingDelta struct {
+	Action          string  `json:"action"`
+	BrightnessDelta float64 `json:"brightness_delta"`
+}
+
+type ColorTemperatureUpdate struct {
+	Mirek int `json:"mirek"`
+}
+
+type ColorUpdate struct {
+	XY XY `json:"xy"`
+}
+
+type DynamicsUpdate struct {
+	Duration int      `json:"duration,omitempty"`
+	Speed    *float64 `json:"speed,omitempty"`
+}
+
+type AlertUpdate struct {
+	Action string `json:"action"`
+}
+
+type GradientUpdate struct {
+	Points []GradientPoint `json:"points"`
+}
+
+type EffectsUpdate struct {
+	Effect string `json:"effect"`
+}
+
+// Group is a room or a zone.
+type Group struct {
+	ID       string               `json:"id"`
+	IDV1     string               `json:"id_v1"`
+	Type     string               `json:"type"`
+	Metadata Metadata             `json:"metadata"`
+	Children []ResourceIdentifier `json:"children"`
+	Services []ResourceIdentifier `json:"services"`
+}
+
+type GroupedLight struct {
+	ID      string             `json:"id"`
+	IDV1    string             `json:"id_v1"`
+	Owner   ResourceIdentifier `json:"owner"`
+	On      *On                `json:"on"`
+	Dimming *Dimming           `json:"dimming"`
+}
+
+type SceneAction struct {
+	Target ResourceIdentifier `json:"target"`
+	Action struct {
+		On               *On                     `json:"on,omitempty"`
+		Dimming          *DimmingUpdate          `json:"dimming,omitempty"`
+		Color            *ColorUpdate            `json:"color,omitempty"`
+		ColorTemperature *ColorTemperatureUpdate `json:"color_temperature,omitempty"`
+		Gradient         *GradientUpdate         `json:"gradient,omitempty"`
+		Effects          *EffectsUpdate          `json:"effects,omitempty"`
+		Dynamics         *DynamicsUpdate         `json:"dynamics,omitempty"`
+	} `json:"action"`
+}
+
+type Scene struct {
+	ID          string             `json:"id,omitempty"`
+	IDV1        string             `json:"id_v1,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Metadata    Metadata           `json:"metadata"`
+	Group       ResourceIdentifier `json:"group"`
+	Actions     []SceneAction      `json:"actions"`
+	Speed       float64            `json:"speed,omitempty"`
+	AutoDynamic bool               `json:"auto_dynamic,omitempty"`
+}
+
+type ProductData struct {
+	ModelID          string `json:"model_id"`
+	ManufacturerName string `json:"manufacturer_name"`
+	ProductName      string `json:"product_name"`
+	ProductArchetype string `json:"product_archetype"`
+	Certified        bool   `json:"certified"`
+	SoftwareVersion  string `json:"software_version"`
+}
+
+type Device struct {
+	ID          string               `json:"id"`
+	IDV1        string               `json:"id_v1"`
+	ProductData ProductData          `json:"product_data"`
+	Metadata    Metadata             `json:"metadata"`
+	Services    []ResourceIdentifier `json:"services"`
+}
+
+type Motion struct {
+	ID      string             `json:"id"`
+	IDV1    string             `json:"id_v1"`
+	Owner   ResourceIdentifier `json:"owner"`
+	Enabled bool               `json:"enabled"`
+	Motion  struct {
+		Motion      bool `json:"motion"`
+		MotionValid bool `json:"motion_valid"`
+	} `json:"motion"`
+}
+
+type Button struct {
+	ID       string             `json:"id"`
+	IDV1     string             `json:"id_v1"`
+	Owner    ResourceIdentifier `json:"owner"`
+	Metadata struct {
+		ControlID int `json:"control_id"`
+	} `json:"metadata"`
+	Button struct {
+		LastEvent      string `json:"last_event"`
+		RepeatInterval int    `json:"repeat_interval"`
+	} `json:"button"`
+}
+
+// Resource is the part common to every resource, used to map between v1 and v2 IDs.
+type Resource struct {
+	ID   string `json:"id"`
+	IDV1 string `json:"id_v1"`
+	Type string `json:"type"`
+}
@@ -0,0 +1,350 @@
+// Package color parses human-friendly light settings, such as "red",
+// "#ffaa00", "rgb(255, 170, 0)", "2700K", "370mired", "warm", "50%" or
+// "+10%", into the values used by the bridge.
+package color
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	MinBri = 1
+	MaxBri = 254
+	MaxHue = 65535
+	MaxSat = 254
+
+	// MinCT and MaxCT are the color temperature range, in mireds, supported
+	// by most bulbs.
+	MinCT = 153
+	MaxCT = 500
+)
+
+// Color is either a color temperature (Mode "ct") or a color with both its
+// CIE coordinates and its hue and saturation (Mode "xy"), so that it can be
+// sent in whichever form a light supports.
+type Color struct {
+	Mode string
+	XY   []float32
+	CT   uint16
+	Hue  uint16
+	Sat  uint8
+}
+
+// Parse parses a CSS color name, #rgb or #rrggbb, rgb(r, g, b), hsl(h, s%, l%)
+// or a color temperature as accepted by ParseTemperature, except for plain
+// numbers.
+func Parse(s string) (Color, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		if ct, err := ParseTemperature(value); err == nil {
+			return Color{Mode: "ct", CT: ct}, nil
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(value, "#"):
+		return parseHex(s, value[1:])
+	case strings.HasPrefix(value, "rgb(") && strings.HasSuffix(value, ")"):
+		return parseRGB(s, value[len("rgb("):len(value)-1])
+	case strings.HasPrefix(value, "hsl(") && strings.HasSuffix(value, ")"):
+		return parseHSL(s, value[len("hsl("):len(value)-1])
+	}
+
+	name := strings.NewReplacer(" ", "", "-", "", "_", "").Replace(value)
+	if rgb, found := names[name]; found {
+		return FromRGB(uint8(rgb>>16), uint8(rgb>>8), uint8(rgb)), nil
+	}
+	return Color{}, fmt.Errorf("invalid color %q: expected a color name, #rrggbb, rgb(), hsl() or a temperature", s)
+}
+
+func parseHex(s string, hex string) (Color, error) {
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return Color{}, fmt.Errorf("invalid color %q: expected #rgb or #rrggbb", s)
+	}
+	return FromRGB(uint8(rgb>>16), uint8(rgb>>8), uint8(rgb)), nil
+}
+
+// parseRGB parses the arguments of rgb(), as numbers from 0 to 255 or percentages.
+func parseRGB(s string, args string) (Color, error) {
+	parts := splitArgs(args)
+	if len(parts) != 3 {
+		return Color{}, fmt.Errorf("invalid color %q: expected rgb(r, g, b)", s)
+	}
+	var rgb [3]uint8
+	for i, part := range parts {
+		v, err := parseComponent(part, 255)
+		if err != nil {
+			return Color{}, fmt.Errorf("invalid color %q: %v", s, err)
+		}
+		rgb[i] = uint8(math.Floor(v*255 + 0.5))
+	}
+	return FromRGB(rgb[0], rgb[1], rgb[2]), nil
+}
+
+func parseHSL(s string, args string) (Color, error) {
+	parts := splitArgs(args)
+	if len(parts) != 3 {
+		return Color{}, fmt.Errorf("invalid color %q: expected hsl(h, s%%, l%%)", s)
+	}
+	h, err := parseDegrees(parts[0])
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid color %q: %v", s, err)
+	}
+	sat, err := parseComponent(parts[1], 1)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid color %q: %v", s, err)
+	}
+	light, err := parseComponent(parts[2], 1)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid color %q: %v", s, err)
+	}
+
+	c := (1 - math.Abs(2*light-1)) * sat
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := light - c/2
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	byteOf := func(v float64) uint8 {
+		return uint8(math.Floor((v+m)*255 + 0.5))
+	}
+	return FromRGB(byteOf(r), byteOf(g), byteOf(b)), nil
+}
+
+func splitArgs(args string) []string {
+	return strings.FieldsFunc(args, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// parseComponent parses a percentage, or a number up to max, as a fraction.
+func parseComponent(s string, max float64) (float64, error) {
+	if strings.HasSuffix(s, "%") {
+		max = 100
+		s = strings.TrimSuffix(s, "%")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 || v > max {
+		return 0, fmt.Errorf("%q is out of range", s)
+	}
+	return v / max, nil
+}
+
+func parseDegrees(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "deg"), "°")
+	h, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hue %q", s)
+	}
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	return h, nil
+}
+
+// FromRGB converts an sRGB color.
+func FromRGB(r, g, b uint8) Color {
+	// gamma expansion, then conversion to XYZ with the wide gamut matrix
+	// recommended for Hue lights.
+	linear := func(v uint8) float64 {
+		c := float64(v) / 255
+		if c > 0.04045 {
+			return math.Pow((c+0.055)/1.055, 2.4)
+		}
+		return c / 12.92
+	}
+	lr, lg, lb := linear(r), linear(g), linear(b)
+	x := lr*0.664511 + lg*0.154324 + lb*0.162028
+	y := lr*0.283881 + lg*0.668433 + lb*0.047685
+	z := lr*0.000088 + lg*0.072310 + lb*0.986039
+
+	color := Color{Mode: "xy", XY: []float32{0.3127, 0.3290}}
+	if x+y+z > 0 {
+		color.XY = []float32{float32(x / (x + y + z)), float32(y / (x + y + z))}
+	}
+
+	// hue and saturation as in HSV.
+	fr, fg, fb := float64(r)/255, float64(g)/255, float64(b)/255
+	max := math.Max(fr, math.Max(fg, fb))
+	min := math.Min(fr, math.Min(fg, fb))
+	delta := max - min
+	var h float64
+	switch {
+	case delta == 0:
+		h = 0
+	case max == fr:
+		h = math.Mod((fg-fb)/delta, 6)
+	case max == fg:
+		h = (fb-fr)/delta + 2
+	default:
+		h = (fr-fg)/delta + 4
+	}
+	if h < 0 {
+		h += 6
+	}
+	color.Hue = uint16(math.Floor(h/6*MaxHue + 0.5))
+	if max > 0 {
+		color.Sat = uint8(math.Floor(delta/max*MaxSat + 0.5))
+	}
+	return color
+}
+
+// ParseTemperature parses a color temperature in kelvin ("2700K"), in mireds
+// ("370mired" or just "370"), or by name: candle, warm, neutral, cool,
+// daylight, warmest or coolest. It returns mireds, which may be outside the
+// range supported by a bulb; see ClampCT.
+func ParseTemperature(s string) (uint16, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	switch value {
+	case "warmest":
+		return MaxCT, nil
+	case "coolest":
+		return MinCT, nil
+	}
+	if kelvin, found := temperatures[value]; found {
+		return kelvinToMireds(float64(kelvin)), nil
+	}
+
+	if strings.HasSuffix(value, "k") {
+		kelvin, err := strconv.ParseFloat(strings.TrimSuffix(value, "k"), 64)
+		if err != nil || kelvin < 1000 || kelvin > 40000 {
+			return 0, fmt.Errorf("invalid temperature %q: expected 1000K to 40000K", s)
+		}
+		return kelvinToMireds(kelvin), nil
+	}
+
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "s"), "mired")
+	mireds, err := strconv.ParseUint(strings.TrimSpace(value), 10, 16)
+	if err != nil || mireds == 0 {
+		return 0, fmt.Errorf("invalid temperature %q: expected kelvin (2700K), mireds (370mired) or a name such as warm", s)
+	}
+	return uint16(mireds), nil
+}
+
+func kelvinToMireds(kelvin float64) uint16 {
+	return uint16(math.Floor(1e6/kelvin + 0.5))
+}
+
+// ClampCT restricts a color temperature to a bulb's range. A zero min or max
+// means the bulb's range is unknown, and MinCT or MaxCT is used instead.
+func ClampCT(ct, min, max uint16) uint16 {
+	if min == 0 {
+		min = MinCT
+	}
+	if max == 0 {
+		max = MaxCT
+	}
+	if ct < min {
+		return min
+	} else if ct > max {
+		return max
+	}
+	return ct
+}
+
+// Brightness is an absolute brightness, or a change to the current one.
+type Brightness struct {
+	Value    uint8
+	Delta    int16
+	Relative bool
+}
+
+// ParseBrightness parses a brightness from 1 to 254 or a percentage, either
+// absolute ("50%", "127") or relative to the current brightness ("+10%",
+// "-20"). Absolute percentages are never rounded down to 0.
+func ParseBrightness(s string) (Brightness, error) {
+	value := strings.TrimSpace(s)
+	relative := strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+
+	var bri float64
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent < -100 || percent > 100 {
+			return Brightness{}, fmt.Errorf("invalid brightness %q: expected a percentage between 0%% and 100%%", s)
+		}
+		bri = math.Floor(percent*MaxBri/100 + 0.5)
+		if !relative && bri < MinBri {
+			bri = MinBri
+		}
+	} else {
+		n, err := strconv.ParseInt(value, 10, 16)
+		if err != nil || n < -MaxBri || n > MaxBri || (!relative && n < MinBri) {
+			return Brightness{}, fmt.Errorf("invalid brightness %q: expected %d to %d, or a percentage", s, MinBri, MaxBri)
+		}
+		bri = float64(n)
+	}
+
+	if relative {
+		return Brightness{Delta: int16(bri), Relative: true}, nil
+	}
+	return Brightness{Value: uint8(bri)}, nil
+}
+
+// Apply returns the brightness resulting from b, given the current one.
+func (b Brightness) Apply(current uint8) uint8 {
+	if !b.Relative {
+		return b.Value
+	}
+	bri := int(current) + int(b.Delta)
+	if bri < MinBri {
+		return MinBri
+	} else if bri > MaxBri {
+		return MaxBri
+	}
+	return uint8(bri)
+}
+
+// ParseHue parses a hue in degrees ("30deg") or in the bridge's 0-65535 range.
+func ParseHue(s string) (uint16, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	if strings.HasSuffix(value, "deg") || strings.HasSuffix(value, "°") {
+		h, err := parseDegrees(value)
+		if err != nil {
+			return 0, err
+		}
+		return uint16(math.Floor(h/360*MaxHue + 0.5)), nil
+	}
+	h, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hue %q: expected degrees (30deg) or 0 to %d", s, MaxHue)
+	}
+	return uint16(h), nil
+}
+
+// ParseSaturation parses a percentage or a saturation from 0 to 254.
+func ParseSaturation(s string) (uint8, error) {
+	value := strings.TrimSpace(s)
+	if strings.HasSuffix(value, "%") {
+		v, err := parseComponent(value, MaxSat)
+		if err != nil {
+			return 0, fmt.Errorf("invalid saturation %q: expected 0%% to 100%%", s)
+		}
+		return uint8(math.Floor(v*MaxSat + 0.5)), nil
+	}
+	sat, err := strconv.ParseUint(value, 10, 8)
+	if err != nil || sat > MaxSat {
+		return 0, fmt.Errorf("invalid saturation %q: expected 0 to %d, or a percentage", s, MaxSat)
+	}
+	return uint8(sat), nil
+}
@@ -0,0 +1,111 @@
+package color
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColors(t *testing.T) {
+	red, err := Parse("red")
+	assert.Nil(t, err)
+	assert.Equal(t, "xy", red.Mode)
+	assert.InDelta(t, 0.7006, red.XY[0], 0.001)
+	assert.InDelta(t, 0.2993, red.XY[1], 0.001)
+	assert.Equal(t, uint16(0), red.Hue)
+	assert.Equal(t, uint8(MaxSat), red.Sat)
+
+	for _, s := range []string{"#ff0000", "#F00", "rgb(255, 0, 0)", "rgb(100%,0%,0%)", "hsl(0, 100%, 50%)", "hsl(360deg 100% 50%)"} {
+		color, err := Parse(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, red, color, s)
+	}
+
+	orange, err := Parse("#ffaa00")
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(7282), orange.Hue)
+
+	darkOrange, err := Parse("Dark Orange")
+	assert.Nil(t, err)
+	assert.Equal(t, FromRGB(0xff, 0x8c, 0x00), darkOrange)
+
+	white, err := Parse("white")
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0), white.Sat)
+
+	warm, err := Parse("warm")
+	assert.Nil(t, err)
+	assert.Equal(t, Color{Mode: "ct", CT: 370}, warm)
+
+	for _, s := range []string{"", "blurple", "#ff00", "rgb(256, 0, 0)", "hsl(0, 100%)", "370"} {
+		_, err := Parse(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestParseTemperature(t *testing.T) {
+	tests := map[string]uint16{
+		"2700K":     370,
+		"6500k":     154,
+		"370mired":  370,
+		"250mireds": 250,
+		"300":       300,
+		"candle":    500,
+		"warmest":   MaxCT,
+		"coolest":   MinCT,
+	}
+	for s, expected := range tests {
+		ct, err := ParseTemperature(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, ct, s)
+	}
+
+	for _, s := range []string{"hot", "500000K", "0", "-1"} {
+		_, err := ParseTemperature(s)
+		assert.NotNil(t, err, s)
+	}
+
+	assert.Equal(t, uint16(MaxCT), ClampCT(1000, 0, 0))
+	assert.Equal(t, uint16(454), ClampCT(500, 153, 454))
+	assert.Equal(t, uint16(300), ClampCT(300, 153, 454))
+}
+
+func TestParseBrightness(t *testing.T) {
+	tests := map[string]Brightness{
+		"50%":  {Value: 127},
+		"100%": {Value: MaxBri},
+		"0%":   {Value: MinBri},
+		"200":  {Value: 200},
+		"+10%": {Delta: 25, Relative: true},
+		"-10":  {Delta: -10, Relative: true},
+	}
+	for s, expected := range tests {
+		bri, err := ParseBrightness(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, bri, s)
+	}
+
+	for _, s := range []string{"0", "255", "150%", "bright"} {
+		_, err := ParseBrightness(s)
+		assert.NotNil(t, err, s)
+	}
+
+	assert.Equal(t, uint8(MaxBri), Brightness{Delta: 25, Relative: true}.Apply(240))
+	assert.Equal(t, uint8(MinBri), Brightness{Delta: -25, Relative: true}.Apply(10))
+	assert.Equal(t, uint8(35), Brightness{Delta: 25, Relative: true}.Apply(10))
+}
+
+func TestParseHueAndSaturation(t *testing.T) {
+	h, err := ParseHue("180deg")
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(32768), h)
+	h, err = ParseHue("1000")
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(1000), h)
+
+	sat, err := ParseSaturation("50%")
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(127), sat)
+	_, err = ParseSaturation("255")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,48 @@
+package hue
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vincentcr/huecontrol/telemetry"
+)
+
+var (
+	requestsTotal = telemetry.DefaultRegistry.NewCounter(
+		"hue_bridge_requests_total",
+		"Requests sent to Hue bridges, by bridge, endpoint and status code or error type.",
+		"bridge", "method", "endpoint", "status")
+
+	requestDuration = telemetry.DefaultRegistry.NewHistogram(
+		"hue_bridge_request_duration_seconds",
+		"Latency of requests sent to Hue bridges.",
+		telemetry.DefaultBuckets,
+		"bridge", "method", "endpoint")
+)
+
+func observeRequest(rawURL string, method string, status string, elapsed time.Duration) {
+	bridge, endpoint := rawURL, rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		bridge, endpoint = u.Host, endpointLabel(u.Path)
+	}
+	requestsTotal.Inc(bridge, method, endpoint, status)
+	requestDuration.Observe(elapsed.Seconds(), bridge, method, endpoint)
+}
+
+// endpointLabel strips the username from a bridge path and replaces resource
+// IDs, so that "/api/<username>/lights/3/state" becomes "/lights/:id/state".
+func endpointLabel(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 2 && parts[1] == "nupnp" {
+		return path
+	} else if len(parts) >= 2 && parts[0] == "api" {
+		parts = parts[2:]
+	}
+	for i := 1; i < len(parts); i += 2 {
+		if parts[i] != "new" {
+			parts[i] = ":id"
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
@@ -1,7 +1,5 @@
 package hue
 
-import "time"
-
 type Light struct {
 	Name    string
 	UID     string `json:"uniqueid"`
@@ -11,8 +9,17 @@ type Light struct {
 	State   struct {
 		lightSettings
 		Reachable bool
-		alert     string
-		colorMode string `json:"colormode"`
+		Alert     string
+		ColorMode string `json:"colormode"`
+	}
+	Capabilities struct {
+		Control struct {
+			// CT is the supported color temperature range, in mireds.
+			CT struct {
+				Min uint16
+				Max uint16
+			}
+		}
 	}
 }
 
@@ -26,12 +33,38 @@ type lightSettings struct {
 	Effect string
 }
 
+// StateUpdate changes the state of a light or the action of a group. Only the
+// fields that are set are sent to the bridge.
+type StateUpdate struct {
+	On             *bool     `json:"on,omitempty"`
+	Bri            *uint8    `json:"bri,omitempty"`
+	BriInc         *int16    `json:"bri_inc,omitempty"`
+	Hue            *uint16   `json:"hue,omitempty"`
+	Sat            *uint8    `json:"sat,omitempty"`
+	XY             []float32 `json:"xy,omitempty"`
+	CT             *uint16   `json:"ct,omitempty"`
+	Alert          string    `json:"alert,omitempty"`
+	Effect         string    `json:"effect,omitempty"`
+	TransitionTime *uint16   `json:"transitiontime,omitempty"`
+	Scene          string    `json:"scene,omitempty"`
+}
+
+// Scene types: a GroupScene belongs to Group and follows its lights, while a
+// LightScene has a fixed list of Lights.
+const (
+	GroupScene = "GroupScene"
+	LightScene = "LightScene"
+)
+
 type Scene struct {
 	ID             string
 	Name           string
+	Type           string
+	Group          string
 	Lights         []string
 	TransitionTime int16 `json:"transitiontime"`
-	lightSettings
+	// LightStates is only returned when getting a single scene.
+	LightStates map[string]StateUpdate `json:"lightstates,omitempty"`
 }
 
 type Group struct {
@@ -40,6 +73,12 @@ type Group struct {
 	Type   string
 	Lights []string
 	Action lightSettings
+	State  *GroupState `json:"state,omitempty"`
+}
+
+type GroupState struct {
+	AllOn bool `json:"all_on"`
+	AnyOn bool `json:"any_on"`
 }
 
 type GroupAction struct {
@@ -47,16 +86,33 @@ type GroupAction struct {
 	Scene string
 }
 
+// Sensor state and config attributes depend on the sensor type, e.g.
+// "buttonevent" for switches or "presence" for motion sensors.
+type Sensor struct {
+	ID      string
+	Name    string
+	Type    string
+	ModelID string `json:"modelid"`
+	UID     string `json:"uniqueid"`
+	State   map[string]interface{}
+	Config  map[string]interface{}
+}
+
 type Schedule struct {
 	ID          string
 	Name        string
 	Description string
-	Command     struct {
-		Address string
-		Body    interface{}
-		Method  string
-	}
-	Status    string
-	Created   time.Time
-	LocalTime time.Time `json:"localtime"`
+	Command     Command
+	Status      string
+	AutoDelete  bool `json:"autodelete"`
+	Created     string
+	// LocalTime is a bridge time pattern, e.g. "2015-10-10T06:00:00",
+	// "W124/T06:00:00" (every weekday at 6am) or "PT00:10:00" (in 10 minutes).
+	LocalTime string `json:"localtime"`
+}
+
+type Command struct {
+	Address string      `json:"address"`
+	Body    interface{} `json:"body"`
+	Method  string      `json:"method"`
 }
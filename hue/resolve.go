@@ -0,0 +1,360 @@
+package hue
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// AllLightsGroupID is the special group that always contains every light.
+const AllLightsGroupID = "0"
+
+// ResolveError is returned when a selector matches nothing, or matches more
+// than one object where exactly one was expected.
+type ResolveError struct {
+	Selector string
+	Kind     string
+	// Matches describes each match as "<id> (<name>)"; it is empty if nothing matched.
+	Matches []string
+}
+
+func (err ResolveError) Error() string {
+	if len(err.Matches) == 0 {
+		return fmt.Sprintf("no %v matches %q", err.Kind, err.Selector)
+	}
+	return fmt.Sprintf("%q is ambiguous: it matches %vs %v", err.Selector, err.Kind, strings.Join(err.Matches, ", "))
+}
+
+// Resolver finds lights and groups from selectors, so that they don't have to
+// be referred to by ID. A selector is one of:
+//
+//	all            every light, or the special group 0
+//	14             an ID
+//	desk lamp      a name, case-insensitive
+//	kitchen*       a glob, case-insensitive
+//	/^desk.*$/     a regular expression matched against names
+//	room:kitchen   the lights of a room, or the room itself; the name part may
+//	               also be a glob or regular expression. zone: and group: work
+//	               the same way for zones and any group.
+//
+// Sensors and scenes are only resolved by ID, name, glob or regular
+// expression, and only if Sensors or Scenes are set. A name matching several
+// objects is ambiguous, while globs and regular expressions may match any
+// number of them.
+type Resolver struct {
+	Lights  []Light
+	Groups  []Group
+	Sensors []Sensor
+	Scenes  []Scene
+}
+
+func NewResolver(lights []Light, groups []Group) *Resolver {
+	return &Resolver{Lights: lights, Groups: groups}
+}
+
+// NewResolver fetches the bridge's lights and groups.
+func (c *Client) NewResolver() (*Resolver, error) {
+	lights, err := c.GetLights()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := c.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+	return NewResolver(lights, groups), nil
+}
+
+var groupPrefixes = map[string]string{
+	"room:":  "Room",
+	"zone:":  "Zone",
+	"group:": "",
+}
+
+// ResolveLights returns all the lights matching selector, in ID order.
+func (r *Resolver) ResolveLights(selector string) ([]Light, error) {
+	var lights []Light
+	if strings.EqualFold(selector, "all") {
+		lights = r.Lights
+	} else if groupType, name, ok := splitGroupSelector(selector); ok {
+		groups, err := r.matchGroups(name, groupType)
+		if err != nil {
+			return nil, err
+		} else if len(groups) > 1 && !isPattern(name) {
+			return nil, ambiguousGroups(selector, groups)
+		}
+		members := make(map[string]bool)
+		for _, group := range groups {
+			for _, id := range group.Lights {
+				members[id] = true
+			}
+		}
+		for _, light := range r.Lights {
+			if members[light.ID] {
+				lights = append(lights, light)
+			}
+		}
+	} else if light, found := r.lightByID(selector); found {
+		lights = []Light{light}
+	} else {
+		match, err := nameMatcher(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, light := range r.Lights {
+			if match(light.Name) {
+				lights = append(lights, light)
+			}
+		}
+		if len(lights) > 1 && !isPattern(selector) {
+			return nil, ambiguousLights(selector, lights)
+		}
+	}
+
+	if len(lights) == 0 {
+		return nil, ResolveError{Selector: selector, Kind: "light"}
+	}
+	return lights, nil
+}
+
+// ResolveLight returns the light matching selector, failing if there is more than one.
+func (r *Resolver) ResolveLight(selector string) (Light, error) {
+	lights, err := r.ResolveLights(selector)
+	if err != nil {
+		return Light{}, err
+	}
+	if len(lights) > 1 {
+		return Light{}, ambiguousLights(selector, lights)
+	}
+	return lights[0], nil
+}
+
+// ResolveGroups returns all the groups matching selector, in ID order. "all"
+// and "0" resolve to group 0.
+func (r *Resolver) ResolveGroups(selector string) ([]Group, error) {
+	var groups []Group
+	var err error
+	if strings.EqualFold(selector, "all") || selector == AllLightsGroupID {
+		groups = []Group{r.allLightsGroup()}
+	} else if groupType, name, ok := splitGroupSelector(selector); ok {
+		groups, err = r.matchGroups(name, groupType)
+		if len(groups) > 1 && !isPattern(name) {
+			return nil, ambiguousGroups(selector, groups)
+		}
+	} else {
+		groups, err = r.matchGroups(selector, "")
+		if len(groups) > 1 && !isPattern(selector) {
+			return nil, ambiguousGroups(selector, groups)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(groups) == 0 {
+		return nil, ResolveError{Selector: selector, Kind: "group"}
+	}
+	return groups, nil
+}
+
+// ResolveGroup returns the group matching selector, failing if there is more than one.
+func (r *Resolver) ResolveGroup(selector string) (Group, error) {
+	groups, err := r.ResolveGroups(selector)
+	if err != nil {
+		return Group{}, err
+	}
+	if len(groups) > 1 {
+		return Group{}, ambiguousGroups(selector, groups)
+	}
+	return groups[0], nil
+}
+
+// ResolveSensors returns all the sensors matching selector, in ID order.
+func (r *Resolver) ResolveSensors(selector string) ([]Sensor, error) {
+	var sensors []Sensor
+	if strings.EqualFold(selector, "all") {
+		sensors = r.Sensors
+	} else {
+		for _, sensor := range r.Sensors {
+			if sensor.ID == selector {
+				return []Sensor{sensor}, nil
+			}
+		}
+		match, err := nameMatcher(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, sensor := range r.Sensors {
+			if match(sensor.Name) {
+				sensors = append(sensors, sensor)
+			}
+		}
+		if len(sensors) > 1 && !isPattern(selector) {
+			return nil, ambiguousSensors(selector, sensors)
+		}
+	}
+
+	if len(sensors) == 0 {
+		return nil, ResolveError{Selector: selector, Kind: "sensor"}
+	}
+	return sensors, nil
+}
+
+// ResolveScenes returns all the scenes matching selector, in ID order.
+func (r *Resolver) ResolveScenes(selector string) ([]Scene, error) {
+	for _, scene := range r.Scenes {
+		if scene.ID == selector {
+			return []Scene{scene}, nil
+		}
+	}
+
+	match, err := nameMatcher(selector)
+	if err != nil {
+		return nil, err
+	}
+	var scenes []Scene
+	for _, scene := range r.Scenes {
+		if match(scene.Name) {
+			scenes = append(scenes, scene)
+		}
+	}
+	if len(scenes) > 1 && !isPattern(selector) {
+		return nil, ambiguousScenes(selector, scenes)
+	} else if len(scenes) == 0 {
+		return nil, ResolveError{Selector: selector, Kind: "scene"}
+	}
+	return scenes, nil
+}
+
+// ResolveScene returns the scene matching selector, failing if there is more than one.
+func (r *Resolver) ResolveScene(selector string) (Scene, error) {
+	scenes, err := r.ResolveScenes(selector)
+	if err != nil {
+		return Scene{}, err
+	}
+	if len(scenes) > 1 {
+		return Scene{}, ambiguousScenes(selector, scenes)
+	}
+	return scenes[0], nil
+}
+
+// matchGroups matches groups of the given type, or of any type if groupType is empty.
+func (r *Resolver) matchGroups(selector string, groupType string) ([]Group, error) {
+	for _, group := range r.Groups {
+		if group.ID == selector && (groupType == "" || group.Type == groupType) {
+			return []Group{group}, nil
+		}
+	}
+
+	match, err := nameMatcher(selector)
+	if err != nil {
+		return nil, err
+	}
+	var groups []Group
+	for _, group := range r.Groups {
+		if (groupType == "" || group.Type == groupType) && match(group.Name) {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+func (r *Resolver) lightByID(id string) (Light, bool) {
+	for _, light := range r.Lights {
+		if light.ID == id {
+			return light, true
+		}
+	}
+	return Light{}, false
+}
+
+func (r *Resolver) allLightsGroup() Group {
+	group := Group{ID: AllLightsGroupID, Name: "All lights", Type: "LightGroup"}
+	for _, light := range r.Lights {
+		group.Lights = append(group.Lights, light.ID)
+	}
+	return group
+}
+
+func splitGroupSelector(selector string) (string, string, bool) {
+	lower := strings.ToLower(selector)
+	for prefix, groupType := range groupPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return groupType, selector[len(prefix):], true
+		}
+	}
+	return "", "", false
+}
+
+// isPattern tells whether selector is a glob or regular expression rather than a name.
+func isPattern(selector string) bool {
+	return isRegexpSelector(selector) || strings.ContainsAny(selector, "*?[")
+}
+
+func isRegexpSelector(selector string) bool {
+	return len(selector) > 1 && strings.HasPrefix(selector, "/") && strings.HasSuffix(selector, "/")
+}
+
+// nameMatcher returns a function telling whether a name matches selector.
+func nameMatcher(selector string) (func(name string) bool, error) {
+	switch {
+	case isRegexpSelector(selector):
+		re, err := regexp.Compile(selector[1 : len(selector)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", selector, err)
+		}
+		return func(name string) bool {
+			return re.MatchString(name)
+		}, nil
+	case strings.ContainsAny(selector, "*?["):
+		pattern := strings.ToLower(selector)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", selector, err)
+		}
+		return func(name string) bool {
+			matched, _ := path.Match(pattern, strings.ToLower(name))
+			return matched
+		}, nil
+	default:
+		return func(name string) bool {
+			return strings.EqualFold(name, selector)
+		}, nil
+	}
+}
+
+func ambiguousLights(selector string, lights []Light) error {
+	err := ResolveError{Selector: selector, Kind: "light"}
+	for _, light := range lights {
+		err.Matches = append(err.Matches, describeMatch(light.ID, light.Name))
+	}
+	return err
+}
+
+func ambiguousGroups(selector string, groups []Group) error {
+	err := ResolveError{Selector: selector, Kind: "group"}
+	for _, group := range groups {
+		err.Matches = append(err.Matches, describeMatch(group.ID, group.Name))
+	}
+	return err
+}
+
+func ambiguousSensors(selector string, sensors []Sensor) error {
+	err := ResolveError{Selector: selector, Kind: "sensor"}
+	for _, sensor := range sensors {
+		err.Matches = append(err.Matches, describeMatch(sensor.ID, sensor.Name))
+	}
+	return err
+}
+
+func ambiguousScenes(selector string, scenes []Scene) error {
+	err := ResolveError{Selector: selector, Kind: "scene"}
+	for _, scene := range scenes {
+		err.Matches = append(err.Matches, describeMatch(scene.ID, scene.Name))
+	}
+	return err
+}
+
+func describeMatch(id, name string) string {
+	return fmt.Sprintf("%v (%v)", id, name)
+}
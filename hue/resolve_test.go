@@ -0,0 +1,140 @@
+package hue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testResolver() *Resolver {
+	return NewResolver(
+		[]Light{
+			{ID: "1", Name: "Kitchen ceiling"},
+			{ID: "2", Name: "Kitchen counter"},
+			{ID: "3", Name: "Desk"},
+			{ID: "14", Name: "Desk lamp"},
+			{ID: "15", Name: "3"},
+		},
+		[]Group{
+			{ID: "1", Name: "Kitchen", Type: "Room", Lights: []string{"1", "2"}},
+			{ID: "2", Name: "Office", Type: "Room", Lights: []string{"3", "14"}},
+			{ID: "3", Name: "Downstairs", Type: "Zone", Lights: []string{"1", "2", "3"}},
+		},
+	)
+}
+
+func lightIDs(lights []Light) []string {
+	var ids []string
+	for _, light := range lights {
+		ids = append(ids, light.ID)
+	}
+	return ids
+}
+
+func TestResolveLights(t *testing.T) {
+	r := testResolver()
+	tests := []struct {
+		selector string
+		ids      []string
+	}{
+		{"all", []string{"1", "2", "3", "14", "15"}},
+		{"14", []string{"14"}},
+		{"3", []string{"3"}},
+		{"desk LAMP", []string{"14"}},
+		{"kitchen*", []string{"1", "2"}},
+		{"/^Desk( lamp)?$/", []string{"3", "14"}},
+		{"room:office", []string{"3", "14"}},
+		{"zone:down*", []string{"1", "2", "3"}},
+		{"group:1", []string{"1", "2"}},
+	}
+	for _, test := range tests {
+		lights, err := r.ResolveLights(test.selector)
+		assert.Nil(t, err, test.selector)
+		assert.Equal(t, test.ids, lightIDs(lights), test.selector)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	r := testResolver()
+
+	_, err := r.ResolveLights("garage")
+	assert.Equal(t, ResolveError{Selector: "garage", Kind: "light"}, err)
+
+	_, err = r.ResolveLights("room:downstairs")
+	assert.Equal(t, `no light matches "room:downstairs"`, err.Error())
+
+	_, err = r.ResolveLight("desk*")
+	assert.Equal(t, `"desk*" is ambiguous: it matches lights 3 (Desk), 14 (Desk lamp)`, err.Error())
+
+	_, err = r.ResolveLights("/(/")
+	assert.NotNil(t, err)
+	_, err = r.ResolveLights("[")
+	assert.NotNil(t, err)
+}
+
+func TestResolveGroups(t *testing.T) {
+	r := testResolver()
+
+	group, err := r.ResolveGroup("all")
+	assert.Nil(t, err)
+	assert.Equal(t, AllLightsGroupID, group.ID)
+	assert.Equal(t, []string{"1", "2", "3", "14", "15"}, group.Lights)
+
+	group, err = r.ResolveGroup("kitchen")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", group.ID)
+
+	group, err = r.ResolveGroup("zone:3")
+	assert.Nil(t, err)
+	assert.Equal(t, "Downstairs", group.Name)
+
+	_, err = r.ResolveGroup("room:3")
+	assert.NotNil(t, err)
+
+	groups, err := r.ResolveGroups("room:*")
+	assert.Nil(t, err)
+	assert.Len(t, groups, 2)
+
+	_, err = r.ResolveGroup("room:*")
+	assert.Equal(t, `"room:*" is ambiguous: it matches groups 1 (Kitchen), 2 (Office)`, err.Error())
+}
+
+func TestResolveAmbiguousNames(t *testing.T) {
+	r := NewResolver(
+		[]Light{{ID: "1", Name: "Desk"}, {ID: "2", Name: "desk"}, {ID: "3", Name: "Hall"}},
+		[]Group{
+			{ID: "1", Name: "Office", Type: "Room", Lights: []string{"1"}},
+			{ID: "2", Name: "Office", Type: "Zone", Lights: []string{"2"}},
+		},
+	)
+	r.Sensors = []Sensor{{ID: "4", Name: "Motion"}, {ID: "5", Name: "Motion"}}
+	r.Scenes = []Scene{{ID: "a", Name: "Relax"}, {ID: "b", Name: "Relax"}}
+
+	_, err := r.ResolveLights("desk")
+	assert.Equal(t, `"desk" is ambiguous: it matches lights 1 (Desk), 2 (desk)`, err.Error())
+	_, err = r.ResolveGroups("office")
+	assert.Equal(t, `"office" is ambiguous: it matches groups 1 (Office), 2 (Office)`, err.Error())
+	_, err = r.ResolveLights("group:office")
+	assert.Equal(t, `"group:office" is ambiguous: it matches groups 1 (Office), 2 (Office)`, err.Error())
+	_, err = r.ResolveSensors("motion")
+	assert.Equal(t, `"motion" is ambiguous: it matches sensors 4 (Motion), 5 (Motion)`, err.Error())
+	_, err = r.ResolveScenes("relax")
+	assert.Equal(t, `"relax" is ambiguous: it matches scenes a (Relax), b (Relax)`, err.Error())
+
+	// patterns, IDs and a type prefix narrowing the name down are not ambiguous.
+	lights, err := r.ResolveLights("desk*")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, lightIDs(lights))
+	lights, err = r.ResolveLights("/^[Dd]esk$/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, lightIDs(lights))
+	lights, err = r.ResolveLights("room:office")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1"}, lightIDs(lights))
+	light, err := r.ResolveLight("2")
+	assert.Nil(t, err)
+	assert.Equal(t, "desk", light.Name)
+	sensors, err := r.ResolveSensors("all")
+	assert.Nil(t, err)
+	assert.Len(t, sensors, 2)
+}
@@ -0,0 +1,53 @@
+package hue
+
+import "strconv"
+
+// lessID orders bridge IDs numerically where possible, so that "2" comes before "10".
+func lessID(a, b string) bool {
+	i, errA := strconv.Atoi(a)
+	j, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return i < j
+	}
+	return a < b
+}
+
+type byNumericID []string
+
+func (ids byNumericID) Len() int           { return len(ids) }
+func (ids byNumericID) Swap(i, j int)      { ids[i], ids[j] = ids[j], ids[i] }
+func (ids byNumericID) Less(i, j int) bool { return lessID(ids[i], ids[j]) }
+
+type lightsByID []Light
+
+func (lights lightsByID) Len() int           { return len(lights) }
+func (lights lightsByID) Swap(i, j int)      { lights[i], lights[j] = lights[j], lights[i] }
+func (lights lightsByID) Less(i, j int) bool { return lessID(lights[i].ID, lights[j].ID) }
+
+type groupsByID []Group
+
+func (groups groupsByID) Len() int           { return len(groups) }
+func (groups groupsByID) Swap(i, j int)      { groups[i], groups[j] = groups[j], groups[i] }
+func (groups groupsByID) Less(i, j int) bool { return lessID(groups[i].ID, groups[j].ID) }
+
+type scenesByID []Scene
+
+func (scenes scenesByID) Len() int           { return len(scenes) }
+func (scenes scenesByID) Swap(i, j int)      { scenes[i], scenes[j] = scenes[j], scenes[i] }
+func (scenes scenesByID) Less(i, j int) bool { return lessID(scenes[i].ID, scenes[j].ID) }
+
+type sensorsByID []Sensor
+
+func (sensors sensorsByID) Len() int           { return len(sensors) }
+func (sensors sensorsByID) Swap(i, j int)      { sensors[i], sensors[j] = sensors[j], sensors[i] }
+func (sensors sensorsByID) Less(i, j int) bool { return lessID(sensors[i].ID, sensors[j].ID) }
+
+type schedulesByID []Schedule
+
+func (schedules schedulesByID) Len() int { return len(schedules) }
+func (schedules schedulesByID) Swap(i, j int) {
+	schedules[i], schedules[j] = schedules[j], schedules[i]
+}
+func (schedules schedulesByID) Less(i, j int) bool {
+	return lessID(schedules[i].ID, schedules[j].ID)
+}
@@ -0,0 +1,55 @@
+package hue
+
+// CurrentState returns the update that would put a light back in its current
+// state. Only the color attributes of the light's color mode are kept; the
+// state of a light that is off is just "off".
+func (light Light) CurrentState() StateUpdate {
+	state := light.State
+	update := StateUpdate{On: &state.On}
+	if !state.On {
+		return update
+	}
+
+	bri := state.Bri
+	update.Bri = &bri
+	switch state.ColorMode {
+	case "xy":
+		update.XY = append([]float32(nil), state.XY...)
+	case "ct":
+		ct := state.CT
+		update.CT = &ct
+	case "hs":
+		h, sat := state.Hue, state.Sat
+		update.Hue, update.Sat = &h, &sat
+	}
+	return update
+}
+
+// CaptureScene creates a scene from the current state of the group's lights.
+// Group 0 has no scenes of its own, so a light scene is created for it.
+func (c *Client) CaptureScene(name string, group Group) (string, error) {
+	lights, err := c.GetLights()
+	if err != nil {
+		return "", err
+	}
+
+	members := make(map[string]bool)
+	for _, id := range group.Lights {
+		members[id] = true
+	}
+	scene := Scene{Name: name, LightStates: make(map[string]StateUpdate)}
+	for _, light := range lights {
+		if group.ID == AllLightsGroupID || members[light.ID] {
+			scene.Lights = append(scene.Lights, light.ID)
+			scene.LightStates[light.ID] = light.CurrentState()
+		}
+	}
+
+	if group.ID == AllLightsGroupID {
+		scene.Type = LightScene
+	} else {
+		scene.Type = GroupScene
+		scene.Group = group.ID
+	}
+	return c.CreateScene(scene)
+}
@@ -0,0 +1,88 @@
+package hue
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const stateTestLights = `{
+	"1": {"name":"Desk","state":{"on":true,"bri":200,"xy":[0.3,0.4],"ct":366,"colormode":"xy"}},
+	"2": {"name":"Lamp","state":{"on":false,"bri":100,"colormode":"ct","ct":300}},
+	"3": {"name":"Hall","state":{"on":true,"bri":50,"hue":1000,"sat":200,"ct":250,"colormode":"hs"}}
+}`
+
+func TestCurrentState(t *testing.T) {
+	var light Light
+	light.State.On, light.State.Bri = true, 200
+	light.State.XY, light.State.CT, light.State.Hue, light.State.Sat = []float32{0.3, 0.4}, 366, 1000, 200
+
+	light.State.ColorMode = "xy"
+	assert.Equal(t, `{"on":true,"bri":200,"xy":[0.3,0.4]}`, toJSON(t, light.CurrentState()))
+	light.State.ColorMode = "ct"
+	assert.Equal(t, `{"on":true,"bri":200,"ct":366}`, toJSON(t, light.CurrentState()))
+	light.State.ColorMode = "hs"
+	assert.Equal(t, `{"on":true,"bri":200,"hue":1000,"sat":200}`, toJSON(t, light.CurrentState()))
+	light.State.ColorMode = ""
+	assert.Equal(t, `{"on":true,"bri":200}`, toJSON(t, light.CurrentState()))
+
+	light.State.On = false
+	assert.Equal(t, `{"on":false}`, toJSON(t, light.CurrentState()))
+
+	// the update does not share the light's xy slice.
+	light.State.On, light.State.ColorMode = true, "xy"
+	update := light.CurrentState()
+	light.State.XY[0] = 0.5
+	assert.Equal(t, []float32{0.3, 0.4}, update.XY)
+}
+
+func TestCaptureGroupScene(t *testing.T) {
+	bridge := newFakeBridge(map[string]string{"/lights": stateTestLights})
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	client := New(strings.TrimPrefix(server.URL, "http://"), "user")
+	id, err := client.CaptureScene("Evening", Group{ID: "4", Lights: []string{"1", "2"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "101", id)
+
+	if assert.Len(t, bridge.created["/scenes"], 1) {
+		scene := bridge.created["/scenes"][0]
+		assert.Equal(t, "Evening", scene["name"])
+		assert.Equal(t, GroupScene, scene["type"])
+		assert.Equal(t, "4", scene["group"])
+		assert.NotContains(t, scene, "lights")
+		assert.Equal(t, map[string]interface{}{
+			"1": map[string]interface{}{"on": true, "bri": 200.0, "xy": []interface{}{0.3, 0.4}},
+			"2": map[string]interface{}{"on": false},
+		}, scene["lightstates"])
+	}
+}
+
+func TestCaptureAllLightsScene(t *testing.T) {
+	bridge := newFakeBridge(map[string]string{"/lights": stateTestLights})
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	client := New(strings.TrimPrefix(server.URL, "http://"), "user")
+	_, err := client.CaptureScene("Everything", Group{ID: AllLightsGroupID})
+	assert.Nil(t, err)
+
+	if assert.Len(t, bridge.created["/scenes"], 1) {
+		scene := bridge.created["/scenes"][0]
+		assert.Equal(t, LightScene, scene["type"])
+		assert.NotContains(t, scene, "group")
+		assert.Equal(t, []interface{}{"1", "2", "3"}, scene["lights"])
+		states := scene["lightstates"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"on": true, "bri": 50.0, "hue": 1000.0, "sat": 200.0}, states["3"])
+	}
+}
+
+func toJSON(t *testing.T, obj interface{}) string {
+	encoded, err := json.Marshal(obj)
+	assert.Nil(t, err)
+	return string(encoded)
+}
@@ -0,0 +1,115 @@
+package hue
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testBridgeID = "001788fffe123456"
+
+func bridgeCertificate(t *testing.T, commonName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func startBridge(t *testing.T, cert tls.Certificate) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"1":{"name":"desk"}}`))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	return server
+}
+
+func clientFor(server *httptest.Server, pin CertificatePin) *Client {
+	return NewWithTLS(strings.TrimPrefix(server.URL, "https://"), "user", pin.TLSConfig())
+}
+
+func fingerprintOf(t *testing.T, cert tls.Certificate) string {
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	return CertificateFingerprint(parsed)
+}
+
+func TestTLSTrustOnFirstUse(t *testing.T) {
+	cert := bridgeCertificate(t, testBridgeID)
+	server := startBridge(t, cert)
+	defer server.Close()
+
+	var pinned []string
+	pin := CertificatePin{
+		BridgeID: strings.ToUpper(testBridgeID),
+		OnFirstUse: func(fingerprint string) error {
+			pinned = append(pinned, fingerprint)
+			return nil
+		},
+	}
+	client := clientFor(server, pin)
+
+	lights, err := client.GetLights()
+	assert.Nil(t, err)
+	assert.Len(t, lights, 1)
+
+	client.client.Transport.(*http.Transport).CloseIdleConnections()
+	_, err = client.GetLights()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{fingerprintOf(t, cert)}, pinned)
+}
+
+func TestTLSPinnedFingerprint(t *testing.T) {
+	cert := bridgeCertificate(t, testBridgeID)
+	server := startBridge(t, cert)
+	defer server.Close()
+
+	pin := CertificatePin{BridgeID: testBridgeID, Fingerprint: fingerprintOf(t, cert)}
+	_, err := clientFor(server, pin).GetLights()
+	assert.Nil(t, err)
+
+	impostor := bridgeCertificate(t, testBridgeID)
+	pin.Fingerprint = fingerprintOf(t, impostor)
+	_, err = clientFor(server, pin).GetLights()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ErrCertificatePin.Error())
+}
+
+func TestTLSRejectsOtherBridge(t *testing.T) {
+	server := startBridge(t, bridgeCertificate(t, "001788fffe654321"))
+	defer server.Close()
+
+	firstUse := false
+	pin := CertificatePin{
+		BridgeID: testBridgeID,
+		OnFirstUse: func(fingerprint string) error {
+			firstUse = true
+			return nil
+		},
+	}
+	_, err := clientFor(server, pin).GetLights()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ErrCertificateIdentity.Error())
+	assert.False(t, firstUse)
+}
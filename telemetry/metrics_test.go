@@ -0,0 +1,74 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounter("test_requests_total", "Requests.", "endpoint", "status")
+	histogram := r.NewHistogram("test_duration_seconds", "Durations.", []float64{0.1, 1}, "endpoint")
+
+	counter.Inc("/lights/:id", "200")
+	counter.Inc("/lights/:id", "200")
+	counter.Inc("/groups", "decoding")
+	counter.Inc(`we"ird\`, "200")
+	histogram.Observe(0.05, "/lights")
+	histogram.Observe(0.5, "/lights")
+
+	var buf bytes.Buffer
+	assert.Nil(t, r.Write(&buf))
+
+	expected := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{endpoint="/groups",status="decoding"} 1
+test_requests_total{endpoint="/lights/:id",status="200"} 2
+test_requests_total{endpoint="we\"ird\\",status="200"} 1
+# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{endpoint="/lights",le="0.1"} 1
+test_duration_seconds_bucket{endpoint="/lights",le="1"} 2
+test_duration_seconds_bucket{endpoint="/lights",le="+Inf"} 2
+test_duration_seconds_sum{endpoint="/lights"} 0.55
+test_duration_seconds_count{endpoint="/lights"} 2
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+type recordingExporter struct {
+	spans []*Span
+}
+
+func (e *recordingExporter) ExportSpan(span *Span) {
+	e.spans = append(e.spans, span)
+}
+
+func TestTracePropagation(t *testing.T) {
+	exporter := &recordingExporter{}
+	SetExporter(exporter)
+	defer SetExporter(discardExporter{})
+
+	incoming, _ := http.NewRequest("GET", "/api/1.0.0/users/me", nil)
+	incoming.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, server := StartSpanFromRequest(incoming, "api GET")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", server.ParentID)
+
+	_, client := StartSpan(ctx, "hue GET")
+	assert.Equal(t, server.TraceID, client.TraceID)
+	assert.Equal(t, server.SpanID, client.ParentID)
+
+	outgoing := http.Header{}
+	InjectHeader(context.WithValue(ctx, spanKey{}, client), outgoing)
+	assert.Equal(t, "00-"+client.TraceID+"-"+client.SpanID+"-01", outgoing.Get("traceparent"))
+
+	client.Finish()
+	server.Finish()
+	assert.Equal(t, []*Span{client, server}, exporter.spans)
+}
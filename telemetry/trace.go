@@ -0,0 +1,157 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const traceparentHeader = "traceparent"
+
+// Span is one timed operation of a trace. Spans started from a context that
+// already holds a span become its children; the trace is carried to other
+// services in the W3C traceparent header.
+type Span struct {
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+
+	mutex sync.Mutex
+}
+
+type Exporter interface {
+	ExportSpan(span *Span)
+}
+
+type discardExporter struct{}
+
+func (discardExporter) ExportSpan(span *Span) {}
+
+// LogExporter writes finished spans to the standard logger.
+type LogExporter struct{}
+
+func (LogExporter) ExportSpan(span *Span) {
+	log.Println(span)
+}
+
+var (
+	exporterMutex sync.Mutex
+	exporter      Exporter = discardExporter{}
+)
+
+// SetExporter replaces the exporter finished spans are sent to. By default spans are discarded.
+func SetExporter(e Exporter) {
+	exporterMutex.Lock()
+	defer exporterMutex.Unlock()
+	exporter = e
+}
+
+type spanKey struct{}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	span := &Span{
+		SpanID:     newID(8),
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: map[string]string{},
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		span.TraceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartSpanFromRequest starts a span continuing the trace of an incoming
+// request, if it carries a valid traceparent header.
+func StartSpanFromRequest(r *http.Request, name string) (context.Context, *Span) {
+	ctx, span := StartSpan(r.Context(), name)
+	if traceID, parentID, ok := parseTraceparent(r.Header.Get(traceparentHeader)); ok {
+		span.TraceID = traceID
+		span.ParentID = parentID
+	}
+	return ctx, span
+}
+
+// InjectHeader propagates the span in ctx to an outgoing request.
+func InjectHeader(ctx context.Context, header http.Header) {
+	if span := SpanFromContext(ctx); span != nil {
+		header.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-01", span.TraceID, span.SpanID))
+	}
+}
+
+var traceparentRe = regexp.MustCompile("^00-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$")
+
+func parseTraceparent(header string) (string, string, bool) {
+	match := traceparentRe.FindStringSubmatch(strings.TrimSpace(header))
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
+
+func (span *Span) SetAttribute(key string, value string) {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.Attributes[key] = value
+}
+
+func (span *Span) Finish() {
+	span.mutex.Lock()
+	span.End = time.Now()
+	span.mutex.Unlock()
+
+	exporterMutex.Lock()
+	e := exporter
+	exporterMutex.Unlock()
+	e.ExportSpan(span)
+}
+
+func (span *Span) Duration() time.Duration {
+	return span.End.Sub(span.Start)
+}
+
+func (span *Span) String() string {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	keys := make([]string, 0, len(span.Attributes))
+	for key := range span.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	attrs := make([]string, len(keys))
+	for i, key := range keys {
+		attrs[i] = key + "=" + span.Attributes[key]
+	}
+
+	return fmt.Sprintf("Span[%s, trace:%s, span:%s, parent:%s, duration:%v, %s]",
+		span.Name, span.TraceID, span.SpanID, span.ParentID, span.Duration(), strings.Join(attrs, " "))
+}
+
+func newID(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("unable to generate %v bytes of randomness: %v", size, err))
+	}
+	return hex.EncodeToString(buf)
+}